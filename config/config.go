@@ -3,8 +3,14 @@ package config
 import (
 	"log"
 	"os"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/joho/godotenv"
+	"golang.org/x/crypto/bcrypt"
 )
 
 type Config struct {
@@ -13,6 +19,147 @@ type Config struct {
 	SMTPPort      string
 	EMAILSender   string
 	EMAILPassword string
+	PasswordCost  int
+
+	AccessTokenTTL  time.Duration
+	RefreshTokenTTL time.Duration
+
+	// Verification and password reset codes
+	CodeTTL         time.Duration
+	CodeMaxAttempts int
+
+	// AuthMode is "opaque" (access tokens looked up in the sessions table)
+	// or "jwt" (signed access tokens verified without a database lookup)
+	AuthMode      string
+	JWTAlgorithm  string
+	JWTKeysDir    string
+	JWTSigningKID string
+
+	// AutoCompleteOrders enables the job that moves orders older than
+	// AutoCompleteAfter to delivered, for setups where staff do not advance
+	// orders through the kitchen API
+	AutoCompleteOrders   bool
+	AutoCompleteAfter    time.Duration
+	AutoCompleteInterval time.Duration
+
+	// WebhookDeliveryInterval is how often queued webhook deliveries are sent
+	WebhookDeliveryInterval time.Duration
+
+	// EventsBroker is "memory" (order events reach clients of this process
+	// only) or "postgres" (events go through LISTEN/NOTIFY to every replica)
+	EventsBroker string
+
+	// Currency is the ISO 4217 code foods and promotions are priced in when
+	// a request does not name one
+	Currency string
+
+	// TaxRate is the tax percent for categories without their own rate and
+	// for fees. With TaxInclusive, food prices already contain tax.
+	// ServiceCharge is a percent of the discounted subtotal and PackagingFee
+	// an amount in minor units added to every order.
+	TaxRate       float64
+	TaxInclusive  bool
+	ServiceCharge float64
+	PackagingFee  int64
+
+	// PaymentMethods are the enabled payment providers, "cash" (paid on
+	// delivery) and "card" (a fake card processor for development); the
+	// first is used when an order does not name one. FakeCardSecret signs
+	// the card provider's webhooks, and payments still pending after
+	// PaymentTimeout cancel their order. The payment job runs every
+	// PaymentInterval.
+	PaymentMethods  []string
+	FakeCardSecret  string
+	PaymentTimeout  time.Duration
+	PaymentInterval time.Duration
+
+	// CancelWindows are the order statuses in which customers may cancel,
+	// each with how long after ordering; 0 means any time
+	CancelWindows map[string]time.Duration
+
+	// Kitchen is where delivery distances are measured from. Delivery costs
+	// DeliveryBaseFee plus DeliveryFeePerKm per kilometer, in minor units,
+	// up to DeliveryMaxDistanceKm (0 for no limit).
+	KitchenLatitude       float64
+	KitchenLongitude      float64
+	DeliveryBaseFee       int64
+	DeliveryFeePerKm      int64
+	DeliveryMaxDistanceKm float64
+
+	// Estimated times of new orders: PrepTime to make one, QueueTime more for
+	// every order ahead of it in the kitchen, and DeliveryTravelTime for
+	// deliveries outside any delivery zone
+	PrepTime           time.Duration
+	QueueTime          time.Duration
+	DeliveryTravelTime time.Duration
+}
+
+// currencyCode matches ISO 4217 alphabetic codes
+var currencyCode = regexp.MustCompile(`^[A-Z]{3}$`)
+
+// getDuration parses a duration environment variable such as "15m" or "720h"
+func getDuration(key string, defaultValue time.Duration) time.Duration {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil || d <= 0 {
+		log.Fatalf("%s must be a positive duration such as 15m or 720h", key)
+	}
+	return d
+}
+
+// getCancelWindows parses CANCEL_WINDOWS, a comma-separated list of
+// status=duration pairs such as "placed=0,accepted=5m"
+func getCancelWindows() map[string]time.Duration {
+	windows := map[string]time.Duration{}
+	value := getEnv("CANCEL_WINDOWS", "payment_pending=0,paid=0,placed=0,accepted=10m")
+	for _, pair := range strings.Split(value, ",") {
+		status, window, ok := strings.Cut(strings.TrimSpace(pair), "=")
+		d, err := time.ParseDuration(window)
+		if !ok || status == "" || err != nil || d < 0 {
+			log.Fatal("CANCEL_WINDOWS must be a comma-separated list of status=duration such as placed=0,accepted=5m")
+		}
+		windows[status] = d
+	}
+	return windows
+}
+
+// getAmount parses an amount in minor units such as "500"
+func getAmount(key string) int64 {
+	amount, err := strconv.ParseInt(getEnv(key, "0"), 10, 64)
+	if err != nil || amount < 0 {
+		log.Fatalf("%s must be a non-negative amount in minor units", key)
+	}
+	return amount
+}
+
+// getFloat parses a number between min and max
+func getFloat(key string, min, max float64) float64 {
+	f, err := strconv.ParseFloat(getEnv(key, "0"), 64)
+	if err != nil || f < min || f > max {
+		log.Fatalf("%s must be a number between %g and %g", key, min, max)
+	}
+	return f
+}
+
+// getPercent parses a percentage environment variable such as "12.5"
+func getPercent(key string) float64 {
+	value := os.Getenv(key)
+	if value == "" {
+		return 0
+	}
+	p, err := strconv.ParseFloat(value, 64)
+	if err != nil || p < 0 || p > 100 {
+		log.Fatalf("%s must be a percentage between 0 and 100", key)
+	}
+	// Rates are stored with two decimals, so an order must not be charged at
+	// a more precise one
+	if _, decimals, _ := strings.Cut(value, "."); len(decimals) > 2 || strings.ContainsAny(value, "eE") {
+		log.Fatalf("%s must have at most 2 decimals", key)
+	}
+	return p
 }
 
 // getEnv gets an environment variable or returns a default value
@@ -24,15 +171,21 @@ func getEnv(key, defaultValue string) string {
 	return value
 }
 
-func Load() Config {
+// LoadDB reads only the database connection string, for commands such as
+// migrate that do not need the rest of the configuration
+func LoadDB() string {
 	// Try to load .env file but don't fail if it doesn't exist
 	_ = godotenv.Load()
 
-	// Get required environment variables
 	dbDNS := os.Getenv("DB_DNS")
 	if dbDNS == "" {
 		log.Fatal("DB_DNS environment variable is required")
 	}
+	return dbDNS
+}
+
+func Load() Config {
+	dbDNS := LoadDB()
 
 	// Get SMTP settings with defaults
 	smtpHost := getEnv("SMTP_HOST", "smtp.gmail.com")
@@ -45,11 +198,104 @@ func Load() Config {
 		log.Fatal("EMAIL_SENDER and EMAIL_PASSWORD environment variables are required")
 	}
 
+	// bcrypt cost for stored passwords; existing hashes are upgraded on login
+	passwordCost, err := strconv.Atoi(getEnv("PASSWORD_HASH_COST", strconv.Itoa(bcrypt.DefaultCost)))
+	if err != nil || passwordCost < bcrypt.MinCost || passwordCost > bcrypt.MaxCost {
+		log.Fatalf("PASSWORD_HASH_COST must be a number between %d and %d", bcrypt.MinCost, bcrypt.MaxCost)
+	}
+
+	codeMaxAttempts, err := strconv.Atoi(getEnv("CODE_MAX_ATTEMPTS", "5"))
+	if err != nil || codeMaxAttempts < 1 {
+		log.Fatal("CODE_MAX_ATTEMPTS must be a positive number")
+	}
+
+	authMode := getEnv("AUTH_MODE", "opaque")
+	if authMode != "opaque" && authMode != "jwt" {
+		log.Fatal("AUTH_MODE must be opaque or jwt")
+	}
+
+	autoComplete, err := strconv.ParseBool(getEnv("AUTO_COMPLETE_ORDERS", "true"))
+	if err != nil {
+		log.Fatal("AUTO_COMPLETE_ORDERS must be true or false")
+	}
+
+	eventsBroker := getEnv("EVENTS_BROKER", "memory")
+	if eventsBroker != "memory" && eventsBroker != "postgres" {
+		log.Fatal("EVENTS_BROKER must be memory or postgres")
+	}
+
+	currency := getEnv("CURRENCY", "USD")
+	if !currencyCode.MatchString(currency) {
+		log.Fatal("CURRENCY must be an ISO 4217 code such as USD or UZS")
+	}
+
+	taxInclusive, err := strconv.ParseBool(getEnv("TAX_INCLUSIVE", "false"))
+	if err != nil {
+		log.Fatal("TAX_INCLUSIVE must be true or false")
+	}
+
+	var paymentMethods []string
+	for _, method := range strings.Split(getEnv("PAYMENT_METHODS", "cash"), ",") {
+		method = strings.TrimSpace(method)
+		if method != "cash" && method != "card" {
+			log.Fatal("PAYMENT_METHODS must be a comma-separated list of cash and card")
+		}
+		paymentMethods = append(paymentMethods, method)
+	}
+	fakeCardSecret := getEnv("FAKE_CARD_WEBHOOK_SECRET", "")
+	if slices.Contains(paymentMethods, "card") && fakeCardSecret == "" {
+		log.Fatal("FAKE_CARD_WEBHOOK_SECRET is required when the card payment method is enabled")
+	}
+
 	return Config{
 		DBNS:          dbDNS,
 		SMPTHost:      smtpHost,
 		SMTPPort:      smtpPort,
 		EMAILSender:   emailSender,
 		EMAILPassword: emailPassword,
+		PasswordCost:  passwordCost,
+
+		AccessTokenTTL:  getDuration("ACCESS_TOKEN_TTL", 15*time.Minute),
+		RefreshTokenTTL: getDuration("REFRESH_TOKEN_TTL", 30*24*time.Hour),
+
+		CodeTTL:         getDuration("CODE_TTL", 10*time.Minute),
+		CodeMaxAttempts: codeMaxAttempts,
+
+		AuthMode:      authMode,
+		JWTAlgorithm:  getEnv("JWT_ALG", "HS256"),
+		JWTKeysDir:    getEnv("JWT_KEYS_DIR", "keys"),
+		JWTSigningKID: getEnv("JWT_SIGNING_KID", ""),
+
+		AutoCompleteOrders:   autoComplete,
+		AutoCompleteAfter:    getDuration("AUTO_COMPLETE_AFTER", 10*time.Minute),
+		AutoCompleteInterval: getDuration("AUTO_COMPLETE_INTERVAL", time.Minute),
+
+		WebhookDeliveryInterval: getDuration("WEBHOOK_DELIVERY_INTERVAL", 10*time.Second),
+
+		EventsBroker: eventsBroker,
+
+		Currency: currency,
+
+		TaxRate:       getPercent("TAX_RATE"),
+		TaxInclusive:  taxInclusive,
+		ServiceCharge: getPercent("SERVICE_CHARGE"),
+		PackagingFee:  getAmount("PACKAGING_FEE"),
+
+		PaymentMethods:  paymentMethods,
+		FakeCardSecret:  fakeCardSecret,
+		PaymentTimeout:  getDuration("PAYMENT_TIMEOUT", 15*time.Minute),
+		PaymentInterval: getDuration("PAYMENT_INTERVAL", 30*time.Second),
+
+		CancelWindows: getCancelWindows(),
+
+		KitchenLatitude:       getFloat("KITCHEN_LATITUDE", -90, 90),
+		KitchenLongitude:      getFloat("KITCHEN_LONGITUDE", -180, 180),
+		DeliveryBaseFee:       getAmount("DELIVERY_BASE_FEE"),
+		DeliveryFeePerKm:      getAmount("DELIVERY_FEE_PER_KM"),
+		DeliveryMaxDistanceKm: getFloat("DELIVERY_MAX_DISTANCE_KM", 0, 20000),
+
+		PrepTime:           getDuration("PREP_TIME", 15*time.Minute),
+		QueueTime:          getDuration("QUEUE_TIME_PER_ORDER", 3*time.Minute),
+		DeliveryTravelTime: getDuration("DELIVERY_TRAVEL_TIME", 20*time.Minute),
 	}
 }
@@ -1,25 +1,22 @@
 package background
 
 import (
-	"github.com/Anwarjondev/fast-food/internal/db"
 	"log"
 	"time"
+
+	"github.com/Anwarjondev/fast-food/internal/repository"
 )
 
-func AutoCompleteOrders() {
-	ticker := time.NewTicker(1 * time.Minute)
+// AutoCompleteOrders moves orders older than age to delivered every interval
+func AutoCompleteOrders(interval, age time.Duration) {
+	ticker := time.NewTicker(interval)
 
 	go func() {
 		for range ticker.C {
-			_, err := db.DB.Exec(`
-				update orders
-				set status = 'completed', delivered_at = now()
-				where status = 'active' and now() -  created_at >interval '10 minutes'
-			`)
+			_, err := repository.AutoCompleteOrders(age)
 			if err != nil {
 				log.Println("Error auto completing orders:", err)
 			}
-			
 		}
 	}()
 }
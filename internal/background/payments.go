@@ -0,0 +1,132 @@
+package background
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/Anwarjondev/fast-food/internal/payment"
+	"github.com/Anwarjondev/fast-food/internal/repository"
+)
+
+const (
+	paymentBatchSize = 20
+	paymentTimeout   = 30 * time.Second
+	paymentRetry     = 5 * time.Minute
+)
+
+// ProcessPayments sends queued refunds, captures the payments of delivered
+// orders, settles the authorized payments of canceled or rejected orders and
+// cancels orders whose payment has been pending for longer than timeout,
+// every interval
+func ProcessPayments(interval, timeout time.Duration) {
+	ticker := time.NewTicker(interval)
+
+	go func() {
+		for range ticker.C {
+			expirePayments(timeout)
+			sendRefunds()
+			settlePayments()
+		}
+	}()
+}
+
+func expirePayments(timeout time.Duration) {
+	ids, err := repository.GetExpiredPayments(timeout)
+	if err != nil {
+		log.Println("Error getting expired payments:", err)
+		return
+	}
+	for _, id := range ids {
+		if _, err := repository.ResolvePayment(id, repository.PaymentExpired, "", "not completed in time"); err != nil {
+			log.Printf("Error expiring payment of order %d: %v", id, err)
+		}
+	}
+}
+
+func settlePayments() {
+	for {
+		// A claimed payment is not picked up again until every call in the
+		// batch could have timed out
+		due, err := repository.ClaimPaymentsToSettle(paymentBatchSize, paymentBatchSize*paymentTimeout)
+		if err != nil {
+			log.Println("Error claiming payments to settle:", err)
+			return
+		}
+		for _, p := range due {
+			settlePayment(p)
+		}
+		if len(due) < paymentBatchSize {
+			return
+		}
+	}
+}
+
+// settlePayment captures what was not refunded of a payment, or voids it
+// when everything was
+func settlePayment(p repository.Payment) {
+	status := repository.PaymentCaptured
+	provider, err := payment.Get(p.Provider)
+	if err == nil {
+		var reference string
+		if p.Reference != nil {
+			reference = *p.Reference
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), paymentTimeout)
+		if left := p.Amount.Sub(p.Refunded); left.Amount > 0 {
+			_, err = provider.Capture(ctx, reference, left)
+		} else {
+			status = repository.PaymentVoided
+			_, err = provider.Void(ctx, reference)
+		}
+		cancel()
+	}
+	if err == nil {
+		err = repository.MarkPaymentSettled(p.ID, status)
+	} else {
+		log.Printf("Settling payment %d of order %d: %v", p.ID, p.OrderID, err)
+		err = repository.MarkCaptureFailed(p.ID, err.Error(), paymentRetry)
+	}
+	if err != nil {
+		log.Printf("Error recording settlement of payment %d: %v", p.ID, err)
+	}
+}
+
+func sendRefunds() {
+	for {
+		due, err := repository.ClaimRefunds(paymentBatchSize, paymentBatchSize*paymentTimeout)
+		if err != nil {
+			log.Println("Error claiming refunds:", err)
+			return
+		}
+		for _, r := range due {
+			sendRefund(r)
+		}
+		if len(due) < paymentBatchSize {
+			return
+		}
+	}
+}
+
+func sendRefund(r repository.DueRefund) {
+	provider, err := payment.Get(r.Provider)
+	var result payment.Result
+	if err == nil {
+		var reference string
+		if r.PaymentReference != nil {
+			reference = *r.PaymentReference
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), paymentTimeout)
+		result, err = provider.Refund(ctx, reference, r.Amount)
+		cancel()
+	}
+	if err == nil {
+		err = repository.MarkRefundSucceeded(r.ID, result.Reference)
+	} else {
+		log.Printf("Refunding %s on order %d: %v", r.Amount, r.OrderID, err)
+		err = repository.MarkRefundFailed(r.ID, err.Error(), paymentRetry)
+	}
+	if err != nil {
+		log.Printf("Error recording refund %d: %v", r.ID, err)
+	}
+}
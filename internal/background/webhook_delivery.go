@@ -0,0 +1,98 @@
+package background
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Anwarjondev/fast-food/internal/repository"
+	"github.com/Anwarjondev/fast-food/internal/utils"
+)
+
+const (
+	webhookBatchSize   = 20
+	webhookTimeout     = 10 * time.Second
+	webhookMaxAttempts = 10
+	webhookFirstRetry  = 30 * time.Second
+	webhookMaxRetry    = 6 * time.Hour
+)
+
+var webhookClient = &http.Client{Timeout: webhookTimeout}
+
+// webhookBackoff is how long to wait after the given number of failed
+// attempts: 30s, 1m, 2m, ... up to 6h. It returns 0 once the delivery is
+// out of attempts.
+func webhookBackoff(attempts int) time.Duration {
+	if attempts >= webhookMaxAttempts {
+		return 0
+	}
+	d := webhookFirstRetry
+	for i := 1; i < attempts && d < webhookMaxRetry; i++ {
+		d *= 2
+	}
+	return min(d, webhookMaxRetry)
+}
+
+// DeliverWebhooks sends queued webhook deliveries every interval
+func DeliverWebhooks(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+
+	go func() {
+		for range ticker.C {
+			for {
+				// A claimed delivery is not picked up again until every
+				// attempt in the batch could have timed out
+				due, err := repository.ClaimWebhookDeliveries(webhookBatchSize, webhookBatchSize*webhookTimeout)
+				if err != nil {
+					log.Println("Error claiming webhook deliveries:", err)
+					break
+				}
+				for _, d := range due {
+					deliverWebhook(d)
+				}
+				if len(due) < webhookBatchSize {
+					break
+				}
+			}
+		}
+	}()
+}
+
+func deliverWebhook(d repository.DueWebhookDelivery) {
+	status, err := postWebhook(d)
+	if err == nil {
+		err = repository.MarkWebhookDelivered(d.ID, status)
+	} else {
+		err = repository.MarkWebhookFailed(d.ID, status, err.Error(), webhookBackoff(d.Attempts+1))
+	}
+	if err != nil {
+		log.Printf("Error recording webhook delivery %d: %v", d.ID, err)
+	}
+}
+
+// postWebhook sends one delivery and returns the response status, or 0 when
+// there was no response
+func postWebhook(d repository.DueWebhookDelivery) (int, error) {
+	body := []byte(d.Payload)
+	req, err := http.NewRequest(http.MethodPost, d.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "fast-food-webhooks")
+	req.Header.Set("X-Webhook-ID", strconv.Itoa(d.ID))
+	req.Header.Set("X-Webhook-Event", d.EventType)
+	req.Header.Set("X-Webhook-Signature", utils.SignWebhook(d.Secret, time.Now().Unix(), body))
+	resp, err := webhookClient.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("endpoint responded with %s", resp.Status)
+	}
+	return resp.StatusCode, nil
+}
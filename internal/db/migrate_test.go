@@ -0,0 +1,72 @@
+package db
+
+import (
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestLoadMigrations(t *testing.T) {
+	fsys := fstest.MapFS{
+		"migrations/0010_orders.up.sql":   {Data: []byte("CREATE TABLE orders ();")},
+		"migrations/0010_orders.down.sql": {Data: []byte("DROP TABLE orders;")},
+		"migrations/0002_users.up.sql":    {Data: []byte("CREATE TABLE users ();")},
+		"migrations/0002_users.down.sql":  {Data: []byte("DROP TABLE users;")},
+		"migrations/0001_init.up.sql":     {Data: []byte("CREATE TABLE food ();")},
+		"migrations/0001_init.down.sql":   {Data: []byte("DROP TABLE food;")},
+	}
+	migrations, err := loadMigrations(fsys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Migration{
+		{1, "init", "CREATE TABLE food ();", "DROP TABLE food;"},
+		{2, "users", "CREATE TABLE users ();", "DROP TABLE users;"},
+		{10, "orders", "CREATE TABLE orders ();", "DROP TABLE orders;"},
+	}
+	if len(migrations) != len(want) {
+		t.Fatalf("loaded %d migrations, want %d", len(migrations), len(want))
+	}
+	for i := range want {
+		if migrations[i] != want[i] {
+			t.Errorf("migration %d = %+v, want %+v", i, migrations[i], want[i])
+		}
+	}
+}
+
+func TestLoadMigrationsRejects(t *testing.T) {
+	tests := []struct {
+		name  string
+		files []string
+		err   string
+	}{
+		{"no down script", []string{"0001_init.up.sql"}, "has no down script"},
+		{"no up script", []string{"0001_init.down.sql"}, "has no up script"},
+		{"bad suffix", []string{"0001_init.sql"}, "expected .up.sql or .down.sql"},
+		{"no name", []string{"0001.up.sql", "0001.down.sql"}, "expected NNNN_name"},
+		{"bad version", []string{"first_init.up.sql", "first_init.down.sql"}, "invalid version"},
+		{"two names", []string{"0001_init.up.sql", "0001_start.down.sql"}, "has two names"},
+	}
+	for _, tt := range tests {
+		fsys := fstest.MapFS{}
+		for _, file := range tt.files {
+			fsys["migrations/"+file] = &fstest.MapFile{Data: []byte("SELECT 1;")}
+		}
+		_, err := loadMigrations(fsys)
+		if err == nil || !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("%s: error = %v, want one containing %q", tt.name, err, tt.err)
+		}
+	}
+}
+
+func TestEmbeddedMigrations(t *testing.T) {
+	migrations, err := loadMigrations(migrationFiles)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, m := range migrations {
+		if m.Version != i+1 {
+			t.Errorf("migration %04d_%s should be version %d; versions must not skip", m.Version, m.Name, i+1)
+		}
+	}
+}
@@ -0,0 +1,6 @@
+DROP TABLE IF EXISTS order_detail;
+DROP TABLE IF EXISTS orders;
+DROP TABLE IF EXISTS food;
+DROP TABLE IF EXISTS category;
+DROP TABLE IF EXISTS confirm;
+DROP TABLE IF EXISTS users;
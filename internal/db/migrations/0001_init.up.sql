@@ -0,0 +1,46 @@
+CREATE TABLE IF NOT EXISTS users (
+	id SERIAL PRIMARY KEY,
+	email VARCHAR,
+	password VARCHAR,
+	is_active BOOL,
+	token TEXT,
+	is_logged_in BOOL
+);
+
+CREATE TABLE IF NOT EXISTS confirm (
+	id SERIAL PRIMARY KEY,
+	user_id INT REFERENCES users(id),
+	code INT,
+	is_passwed BOOL,
+	is_passed BOOL
+);
+
+CREATE TABLE IF NOT EXISTS category (
+	id SERIAL PRIMARY KEY,
+	name VARCHAR
+);
+
+CREATE TABLE IF NOT EXISTS food (
+	id SERIAL PRIMARY KEY,
+	name VARCHAR,
+	category_id INT REFERENCES category(id),
+	img_url TEXT,
+	price FLOAT,
+	count_food INTEGER
+);
+
+CREATE TABLE IF NOT EXISTS orders (
+	id SERIAL PRIMARY KEY,
+	created_at TIMESTAMP,
+	delivered_at TIMESTAMP,
+	user_id INT REFERENCES users(id),
+	status VARCHAR,
+	total_amount NUMERIC
+);
+
+CREATE TABLE IF NOT EXISTS order_detail (
+	id SERIAL PRIMARY KEY,
+	food_id INT REFERENCES food(id),
+	count INT,
+	order_id INT REFERENCES orders(id)
+);
@@ -0,0 +1,7 @@
+ALTER TABLE food
+	DROP COLUMN IF EXISTS sort_order,
+	DROP COLUMN IF EXISTS deleted_at;
+
+ALTER TABLE category
+	DROP COLUMN IF EXISTS sort_order,
+	DROP COLUMN IF EXISTS deleted_at;
@@ -0,0 +1,7 @@
+ALTER TABLE category
+	ADD COLUMN IF NOT EXISTS sort_order INT NOT NULL DEFAULT 0,
+	ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMP;
+
+ALTER TABLE food
+	ADD COLUMN IF NOT EXISTS sort_order INT NOT NULL DEFAULT 0,
+	ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMP;
@@ -0,0 +1,3 @@
+ALTER TABLE users ADD COLUMN IF NOT EXISTS is_admin BOOL NOT NULL DEFAULT false;
+UPDATE users SET is_admin = true WHERE id IN (SELECT user_id FROM user_roles WHERE role = 'admin');
+DROP TABLE IF EXISTS user_roles;
@@ -0,0 +1,18 @@
+-- Every existing user becomes a customer; admins flagged with the old
+-- users.is_admin column keep their access.
+DO $$
+BEGIN
+	IF to_regclass('user_roles') IS NULL THEN
+		CREATE TABLE user_roles (
+			user_id INT NOT NULL REFERENCES users(id),
+			role VARCHAR NOT NULL,
+			granted_at TIMESTAMP NOT NULL DEFAULT now(),
+			PRIMARY KEY (user_id, role)
+		);
+		INSERT INTO user_roles (user_id, role) SELECT id, 'customer' FROM users;
+	END IF;
+	IF EXISTS (SELECT 1 FROM information_schema.columns WHERE table_name = 'users' AND column_name = 'is_admin') THEN
+		INSERT INTO user_roles (user_id, role) SELECT id, 'admin' FROM users WHERE is_admin ON CONFLICT DO NOTHING;
+		ALTER TABLE users DROP COLUMN is_admin;
+	END IF;
+END $$;
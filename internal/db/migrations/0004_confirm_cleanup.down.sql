@@ -0,0 +1,6 @@
+ALTER TABLE confirm
+	DROP COLUMN IF EXISTS created_at,
+	ALTER COLUMN is_passed DROP NOT NULL,
+	ALTER COLUMN is_passed DROP DEFAULT,
+	ADD COLUMN is_passwed BOOL;
+UPDATE confirm SET is_passwed = is_passed;
@@ -0,0 +1,8 @@
+-- confirm had both is_passwed (used by the code) and is_passed, and the code
+-- writes a created_at column that was never created.
+UPDATE confirm SET is_passed = COALESCE(is_passwed, false) OR COALESCE(is_passed, false);
+ALTER TABLE confirm DROP COLUMN is_passwed;
+ALTER TABLE confirm
+	ALTER COLUMN is_passed SET DEFAULT false,
+	ALTER COLUMN is_passed SET NOT NULL,
+	ADD COLUMN IF NOT EXISTS created_at TIMESTAMP NOT NULL DEFAULT now();
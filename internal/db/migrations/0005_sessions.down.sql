@@ -0,0 +1,6 @@
+ALTER TABLE users
+	ADD COLUMN IF NOT EXISTS token TEXT,
+	ADD COLUMN IF NOT EXISTS is_logged_in BOOL;
+
+DROP TABLE IF EXISTS refresh_tokens;
+DROP TABLE IF EXISTS sessions;
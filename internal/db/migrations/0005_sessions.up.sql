@@ -0,0 +1,29 @@
+-- Sessions replace the single users.token column so a user can be logged in
+-- on several devices. Tokens are stored as SHA-256 hashes.
+CREATE TABLE sessions (
+	id SERIAL PRIMARY KEY,
+	user_id INT NOT NULL REFERENCES users(id),
+	access_token_hash VARCHAR NOT NULL UNIQUE,
+	access_expires_at TIMESTAMP NOT NULL,
+	user_agent VARCHAR NOT NULL DEFAULT '',
+	ip VARCHAR NOT NULL DEFAULT '',
+	created_at TIMESTAMP NOT NULL DEFAULT now(),
+	last_refreshed_at TIMESTAMP,
+	revoked_at TIMESTAMP
+);
+CREATE INDEX sessions_user_id_idx ON sessions (user_id);
+
+-- Every refresh token ever issued for a session. A refresh token can be used
+-- once; presenting a used one again revokes the whole session.
+CREATE TABLE refresh_tokens (
+	token_hash VARCHAR PRIMARY KEY,
+	session_id INT NOT NULL REFERENCES sessions(id),
+	expires_at TIMESTAMP NOT NULL,
+	created_at TIMESTAMP NOT NULL DEFAULT now(),
+	used_at TIMESTAMP
+);
+CREATE INDEX refresh_tokens_session_id_idx ON refresh_tokens (session_id);
+
+ALTER TABLE users
+	DROP COLUMN IF EXISTS token,
+	DROP COLUMN IF EXISTS is_logged_in;
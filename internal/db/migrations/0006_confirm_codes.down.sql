@@ -0,0 +1,10 @@
+DROP INDEX IF EXISTS confirm_user_purpose_idx;
+
+UPDATE confirm SET is_passed = true;
+
+ALTER TABLE confirm
+	DROP COLUMN code_hash,
+	DROP COLUMN purpose,
+	DROP COLUMN expires_at,
+	DROP COLUMN attempts,
+	ADD COLUMN code INT;
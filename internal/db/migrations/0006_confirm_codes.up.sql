@@ -0,0 +1,18 @@
+-- Codes are now stored hashed, scoped to a purpose and given an explicit
+-- expiry and attempt counter. Codes issued before this cannot be verified
+-- any more and have to be resent.
+UPDATE confirm SET is_passed = true;
+
+ALTER TABLE confirm
+	DROP COLUMN code,
+	ADD COLUMN code_hash VARCHAR NOT NULL DEFAULT '',
+	ADD COLUMN purpose VARCHAR NOT NULL DEFAULT 'signup',
+	ADD COLUMN expires_at TIMESTAMP NOT NULL DEFAULT now(),
+	ADD COLUMN attempts INT NOT NULL DEFAULT 0;
+
+ALTER TABLE confirm
+	ALTER COLUMN code_hash DROP DEFAULT,
+	ALTER COLUMN purpose DROP DEFAULT,
+	ALTER COLUMN expires_at DROP DEFAULT;
+
+CREATE INDEX confirm_user_purpose_idx ON confirm (user_id, purpose) WHERE NOT is_passed;
@@ -0,0 +1,4 @@
+ALTER TABLE food
+	DROP CONSTRAINT IF EXISTS food_count_food_check,
+	ALTER COLUMN count_food DROP NOT NULL,
+	ALTER COLUMN count_food DROP DEFAULT;
@@ -0,0 +1,8 @@
+-- Stock is checked and decremented when orders are placed, so it must
+-- always be a known, non-negative number.
+UPDATE food SET count_food = 0 WHERE count_food IS NULL OR count_food < 0;
+
+ALTER TABLE food
+	ALTER COLUMN count_food SET DEFAULT 0,
+	ALTER COLUMN count_food SET NOT NULL,
+	ADD CONSTRAINT food_count_food_check CHECK (count_food >= 0);
@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS order_detail_order_id_idx;
+
+ALTER TABLE order_detail
+	DROP COLUMN food_name,
+	DROP COLUMN unit_price;
@@ -0,0 +1,13 @@
+-- Order lines keep the food name and unit price at purchase time so later
+-- menu changes do not rewrite order history. Existing lines are filled from
+-- the current menu, which is the best information available.
+ALTER TABLE order_detail
+	ADD COLUMN food_name VARCHAR NOT NULL DEFAULT '',
+	ADD COLUMN unit_price NUMERIC NOT NULL DEFAULT 0;
+
+UPDATE order_detail d
+SET food_name = COALESCE(f.name, ''), unit_price = COALESCE(f.price, 0)
+FROM food f
+WHERE f.id = d.food_id;
+
+CREATE INDEX IF NOT EXISTS order_detail_order_id_idx ON order_detail (order_id);
@@ -0,0 +1,7 @@
+DROP TABLE IF EXISTS order_status_history;
+
+ALTER TABLE orders ALTER COLUMN status DROP NOT NULL;
+
+UPDATE orders SET status = 'completed' WHERE status = 'delivered';
+UPDATE orders SET status = 'active' WHERE status IN ('placed', 'accepted', 'preparing', 'ready', 'out_for_delivery');
+UPDATE orders SET status = 'canceled' WHERE status IN ('rejected', 'refunded');
@@ -0,0 +1,22 @@
+-- Orders follow a defined lifecycle; see repository/order_status.go.
+-- The old free-form statuses map onto it as active -> placed and
+-- completed -> delivered.
+UPDATE orders SET status = 'placed' WHERE status = 'active';
+UPDATE orders SET status = 'delivered' WHERE status = 'completed';
+
+ALTER TABLE orders ALTER COLUMN status SET NOT NULL;
+
+CREATE TABLE order_status_history (
+	id SERIAL PRIMARY KEY,
+	order_id INT NOT NULL REFERENCES orders(id),
+	from_status VARCHAR,
+	to_status VARCHAR NOT NULL,
+	actor_id INT REFERENCES users(id),
+	reason TEXT NOT NULL DEFAULT '',
+	created_at TIMESTAMP NOT NULL DEFAULT now()
+);
+CREATE INDEX order_status_history_order_id_idx ON order_status_history (order_id);
+
+-- Existing orders start their timeline at the status they have now
+INSERT INTO order_status_history (order_id, from_status, to_status, actor_id, reason, created_at)
+SELECT id, NULL, status, NULL, 'imported', COALESCE(delivered_at, created_at, now()) FROM orders;
@@ -0,0 +1,31 @@
+-- Outgoing webhooks. An empty event_types list subscribes to every event.
+-- The secret signs payloads, so it is stored as is.
+CREATE TABLE webhooks (
+	id SERIAL PRIMARY KEY,
+	url TEXT NOT NULL,
+	secret VARCHAR NOT NULL,
+	event_types VARCHAR[] NOT NULL DEFAULT '{}',
+	is_active BOOLEAN NOT NULL DEFAULT true,
+	created_at TIMESTAMP NOT NULL DEFAULT now(),
+	deleted_at TIMESTAMP
+);
+
+-- The delivery queue and log. Rows are queued in the transaction that
+-- changes the order and stay pending until delivered or out of attempts.
+CREATE TABLE webhook_deliveries (
+	id SERIAL PRIMARY KEY,
+	webhook_id INT NOT NULL REFERENCES webhooks(id),
+	event_id INT NOT NULL,
+	event_type VARCHAR NOT NULL,
+	payload JSONB NOT NULL,
+	status VARCHAR NOT NULL DEFAULT 'pending',
+	attempts INT NOT NULL DEFAULT 0,
+	next_attempt_at TIMESTAMP NOT NULL DEFAULT now(),
+	last_attempt_at TIMESTAMP,
+	response_status INT,
+	last_error TEXT NOT NULL DEFAULT '',
+	created_at TIMESTAMP NOT NULL DEFAULT now(),
+	delivered_at TIMESTAMP
+);
+CREATE INDEX webhook_deliveries_webhook_id_idx ON webhook_deliveries (webhook_id, id);
+CREATE INDEX webhook_deliveries_due_idx ON webhook_deliveries (next_attempt_at) WHERE status = 'pending';
@@ -0,0 +1,10 @@
+-- Each user has one cart: the foods they intend to order and how many.
+-- Prices and stock are read live from food until checkout.
+CREATE TABLE cart_items (
+	user_id INT NOT NULL REFERENCES users(id),
+	food_id INT NOT NULL REFERENCES food(id),
+	count INT NOT NULL CHECK (count > 0),
+	created_at TIMESTAMP NOT NULL DEFAULT now(),
+	updated_at TIMESTAMP NOT NULL DEFAULT now(),
+	PRIMARY KEY (user_id, food_id)
+);
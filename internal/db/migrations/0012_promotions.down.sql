@@ -0,0 +1,7 @@
+ALTER TABLE orders
+	DROP COLUMN IF EXISTS subtotal,
+	DROP COLUMN IF EXISTS discount_amount,
+	DROP COLUMN IF EXISTS promo_code;
+
+DROP TABLE IF EXISTS promotion_redemptions;
+DROP TABLE IF EXISTS promotions;
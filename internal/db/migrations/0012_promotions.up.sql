@@ -0,0 +1,43 @@
+-- Promo codes. kind is percentage (value is the percent off), fixed (value is
+-- the amount off) or buy_x_get_y (for every buy_count + get_count eligible
+-- units the get_count cheapest are free). A category or food limits which
+-- lines a promotion applies to; without either it applies to the whole order.
+CREATE TABLE promotions (
+	id SERIAL PRIMARY KEY,
+	code VARCHAR NOT NULL,
+	kind VARCHAR NOT NULL,
+	value NUMERIC NOT NULL DEFAULT 0,
+	category_id INT REFERENCES category(id),
+	food_id INT REFERENCES food(id),
+	buy_count INT NOT NULL DEFAULT 0,
+	get_count INT NOT NULL DEFAULT 0,
+	min_order NUMERIC NOT NULL DEFAULT 0,
+	max_uses INT,
+	max_uses_per_user INT,
+	starts_at TIMESTAMPTZ,
+	ends_at TIMESTAMPTZ,
+	is_active BOOLEAN NOT NULL DEFAULT true,
+	created_at TIMESTAMP NOT NULL DEFAULT now(),
+	deleted_at TIMESTAMP
+);
+CREATE UNIQUE INDEX promotions_code_key ON promotions (lower(code)) WHERE deleted_at IS NULL;
+
+-- One row per order that used a promotion. Redemptions of canceled or
+-- rejected orders do not count against usage limits.
+CREATE TABLE promotion_redemptions (
+	id SERIAL PRIMARY KEY,
+	promotion_id INT NOT NULL REFERENCES promotions(id),
+	order_id INT NOT NULL UNIQUE REFERENCES orders(id),
+	user_id INT NOT NULL REFERENCES users(id),
+	discount NUMERIC NOT NULL,
+	created_at TIMESTAMP NOT NULL DEFAULT now()
+);
+CREATE INDEX promotion_redemptions_promotion_id_idx ON promotion_redemptions (promotion_id, user_id);
+
+-- total_amount is now subtotal - discount_amount
+ALTER TABLE orders
+	ADD COLUMN subtotal NUMERIC NOT NULL DEFAULT 0,
+	ADD COLUMN discount_amount NUMERIC NOT NULL DEFAULT 0,
+	ADD COLUMN promo_code VARCHAR;
+
+UPDATE orders SET subtotal = COALESCE(total_amount, 0);
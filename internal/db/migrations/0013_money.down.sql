@@ -0,0 +1,23 @@
+ALTER TABLE promotion_redemptions ALTER COLUMN discount TYPE NUMERIC USING discount / 100.0;
+
+ALTER TABLE promotions ALTER COLUMN min_order TYPE NUMERIC USING min_order / 100.0;
+UPDATE promotions SET percent = amount / 100.0 WHERE kind = 'fixed';
+ALTER TABLE promotions
+	DROP COLUMN amount,
+	DROP COLUMN currency;
+ALTER TABLE promotions RENAME COLUMN percent TO value;
+
+ALTER TABLE order_detail ALTER COLUMN unit_price TYPE NUMERIC USING unit_price / 100.0;
+
+ALTER TABLE orders
+	ALTER COLUMN subtotal TYPE NUMERIC USING subtotal / 100.0,
+	ALTER COLUMN discount_amount TYPE NUMERIC USING discount_amount / 100.0,
+	ALTER COLUMN total_amount TYPE NUMERIC USING total_amount / 100.0,
+	ALTER COLUMN total_amount DROP NOT NULL,
+	DROP COLUMN currency;
+
+ALTER TABLE food
+	DROP CONSTRAINT food_price_check,
+	ALTER COLUMN price DROP NOT NULL;
+ALTER TABLE food ALTER COLUMN price TYPE FLOAT USING price / 100.0;
+ALTER TABLE food DROP COLUMN currency;
@@ -0,0 +1,33 @@
+-- Amounts become integer minor units with an ISO 4217 currency code. The
+-- existing decimal amounts are taken to be USD (two decimal places); a
+-- deployment pricing in another currency should fix the currency columns
+-- right after this migration.
+ALTER TABLE food ADD COLUMN currency CHAR(3) NOT NULL DEFAULT 'USD';
+ALTER TABLE food ALTER COLUMN currency DROP DEFAULT;
+ALTER TABLE food ALTER COLUMN price TYPE BIGINT USING round(COALESCE(price, 0) * 100)::BIGINT;
+ALTER TABLE food
+	ALTER COLUMN price SET NOT NULL,
+	ADD CONSTRAINT food_price_check CHECK (price >= 0);
+
+ALTER TABLE orders ADD COLUMN currency CHAR(3) NOT NULL DEFAULT 'USD';
+ALTER TABLE orders ALTER COLUMN currency DROP DEFAULT;
+ALTER TABLE orders
+	ALTER COLUMN subtotal TYPE BIGINT USING round(subtotal * 100)::BIGINT,
+	ALTER COLUMN discount_amount TYPE BIGINT USING round(discount_amount * 100)::BIGINT,
+	ALTER COLUMN total_amount TYPE BIGINT USING round(COALESCE(total_amount, 0) * 100)::BIGINT;
+ALTER TABLE orders ALTER COLUMN total_amount SET NOT NULL;
+
+-- Lines are in the currency of their order
+ALTER TABLE order_detail ALTER COLUMN unit_price TYPE BIGINT USING round(unit_price * 100)::BIGINT;
+
+-- value only ever held a percentage or an amount depending on kind; split
+-- it so amounts can be stored exactly
+ALTER TABLE promotions RENAME COLUMN value TO percent;
+ALTER TABLE promotions
+	ADD COLUMN amount BIGINT NOT NULL DEFAULT 0,
+	ADD COLUMN currency CHAR(3) NOT NULL DEFAULT 'USD';
+ALTER TABLE promotions ALTER COLUMN currency DROP DEFAULT;
+UPDATE promotions SET amount = round(percent * 100)::BIGINT, percent = 0 WHERE kind = 'fixed';
+ALTER TABLE promotions ALTER COLUMN min_order TYPE BIGINT USING round(min_order * 100)::BIGINT;
+
+ALTER TABLE promotion_redemptions ALTER COLUMN discount TYPE BIGINT USING round(discount * 100)::BIGINT;
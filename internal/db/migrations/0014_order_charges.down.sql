@@ -0,0 +1,11 @@
+DROP TABLE order_taxes;
+
+ALTER TABLE order_detail DROP COLUMN tax_rate;
+
+ALTER TABLE orders
+	DROP COLUMN service_charge,
+	DROP COLUMN packaging_fee,
+	DROP COLUMN tax_amount,
+	DROP COLUMN tax_inclusive;
+
+ALTER TABLE category DROP COLUMN tax_rate;
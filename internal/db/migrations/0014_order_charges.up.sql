@@ -0,0 +1,23 @@
+-- Tax rate in percent for the foods of a category; categories without one
+-- use the TAX_RATE setting
+ALTER TABLE category ADD COLUMN tax_rate NUMERIC(5, 2) CHECK (tax_rate >= 0 AND tax_rate <= 100);
+
+-- total_amount is now subtotal - discount_amount + service_charge +
+-- packaging_fee, plus tax_amount unless the prices already included tax
+ALTER TABLE orders
+	ADD COLUMN service_charge BIGINT NOT NULL DEFAULT 0,
+	ADD COLUMN packaging_fee BIGINT NOT NULL DEFAULT 0,
+	ADD COLUMN tax_amount BIGINT NOT NULL DEFAULT 0,
+	ADD COLUMN tax_inclusive BOOLEAN NOT NULL DEFAULT false;
+
+-- The rate each line was taxed at when the order was placed
+ALTER TABLE order_detail ADD COLUMN tax_rate NUMERIC(5, 2) NOT NULL DEFAULT 0;
+
+-- Tax of an order per rate, for receipts and reports
+CREATE TABLE order_taxes (
+	order_id INT NOT NULL REFERENCES orders(id),
+	rate NUMERIC(5, 2) NOT NULL,
+	taxable BIGINT NOT NULL,
+	amount BIGINT NOT NULL,
+	PRIMARY KEY (order_id, rate)
+);
@@ -0,0 +1,20 @@
+-- One payment per order. provider is the payment method (cash, card) and
+-- reference the payment's id at the provider. status is pending until the
+-- provider decides, then authorized (held), deferred (collected on
+-- delivery), declined, failed or expired; authorized and deferred payments
+-- become captured once the order is delivered.
+CREATE TABLE payments (
+	id SERIAL PRIMARY KEY,
+	order_id INT NOT NULL UNIQUE REFERENCES orders(id),
+	provider VARCHAR NOT NULL,
+	reference VARCHAR,
+	status VARCHAR NOT NULL,
+	amount BIGINT NOT NULL,
+	currency CHAR(3) NOT NULL,
+	error VARCHAR NOT NULL DEFAULT '',
+	next_attempt_at TIMESTAMP NOT NULL DEFAULT now(),
+	created_at TIMESTAMP NOT NULL DEFAULT now(),
+	updated_at TIMESTAMP NOT NULL DEFAULT now()
+);
+CREATE UNIQUE INDEX payments_provider_reference_key ON payments (provider, reference) WHERE reference IS NOT NULL;
+CREATE INDEX payments_status_idx ON payments (status, next_attempt_at);
@@ -0,0 +1,3 @@
+ALTER TABLE payments DROP COLUMN refunded;
+
+DROP TABLE refunds;
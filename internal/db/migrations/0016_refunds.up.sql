@@ -0,0 +1,26 @@
+-- Money given back on a payment when its order is canceled or rejected.
+-- Refunds are queued in the same transaction as the order change and sent to
+-- the provider by a background job; status is pending until the provider
+-- confirms, then succeeded. actor_id is who canceled or rejected the order,
+-- NULL for the system. payments.refunded is the total of an order's refunds,
+-- so the rest of an authorized payment can still be captured.
+CREATE TABLE refunds (
+	id SERIAL PRIMARY KEY,
+	order_id INT NOT NULL REFERENCES orders(id),
+	payment_id INT NOT NULL REFERENCES payments(id),
+	amount BIGINT NOT NULL CHECK (amount > 0),
+	currency CHAR(3) NOT NULL,
+	reason VARCHAR NOT NULL,
+	actor_id INT REFERENCES users(id),
+	status VARCHAR NOT NULL,
+	reference VARCHAR,
+	error VARCHAR NOT NULL DEFAULT '',
+	next_attempt_at TIMESTAMP NOT NULL DEFAULT now(),
+	created_at TIMESTAMP NOT NULL DEFAULT now(),
+	updated_at TIMESTAMP NOT NULL DEFAULT now()
+);
+CREATE INDEX refunds_order_id_idx ON refunds (order_id);
+CREATE INDEX refunds_status_idx ON refunds (status, next_attempt_at);
+CREATE INDEX refunds_created_at_idx ON refunds (created_at);
+
+ALTER TABLE payments ADD COLUMN refunded BIGINT NOT NULL DEFAULT 0;
@@ -0,0 +1,7 @@
+ALTER TABLE orders
+	DROP COLUMN fulfillment,
+	DROP COLUMN address_id,
+	DROP COLUMN delivery_address,
+	DROP COLUMN delivery_fee;
+
+DROP TABLE addresses;
@@ -0,0 +1,29 @@
+-- A user's address book. A user has at most one default address, used for
+-- delivery orders that do not name one.
+CREATE TABLE addresses (
+	id SERIAL PRIMARY KEY,
+	user_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+	label VARCHAR NOT NULL DEFAULT '',
+	line1 VARCHAR NOT NULL,
+	line2 VARCHAR NOT NULL DEFAULT '',
+	city VARCHAR NOT NULL,
+	postal_code VARCHAR NOT NULL DEFAULT '',
+	phone VARCHAR NOT NULL,
+	latitude DOUBLE PRECISION NOT NULL,
+	longitude DOUBLE PRECISION NOT NULL,
+	courier_notes VARCHAR NOT NULL DEFAULT '',
+	is_default BOOLEAN NOT NULL DEFAULT false,
+	created_at TIMESTAMP NOT NULL DEFAULT now(),
+	updated_at TIMESTAMP NOT NULL DEFAULT now()
+);
+CREATE INDEX addresses_user_id_idx ON addresses (user_id);
+CREATE UNIQUE INDEX addresses_default_key ON addresses (user_id) WHERE is_default;
+
+-- Orders are delivered or picked up. Delivery orders keep a copy of their
+-- address as it was when they were placed, so later edits to the address book
+-- do not change where an order goes. Earlier orders count as pickups.
+ALTER TABLE orders
+	ADD COLUMN fulfillment VARCHAR NOT NULL DEFAULT 'pickup',
+	ADD COLUMN address_id INT REFERENCES addresses(id) ON DELETE SET NULL,
+	ADD COLUMN delivery_address JSONB,
+	ADD COLUMN delivery_fee BIGINT NOT NULL DEFAULT 0;
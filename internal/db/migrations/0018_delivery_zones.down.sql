@@ -0,0 +1,5 @@
+ALTER TABLE orders
+	DROP COLUMN delivery_zone_id,
+	DROP COLUMN estimated_at;
+
+DROP TABLE delivery_zones;
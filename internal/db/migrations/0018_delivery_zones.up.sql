@@ -0,0 +1,22 @@
+-- Areas delivered to. area is a GeoJSON Polygon or MultiPolygon; delivery
+-- orders must fall inside an active zone once any exist, and pay its
+-- delivery_fee if they reach its min_order. travel_minutes is how long the
+-- courier takes to get there.
+CREATE TABLE delivery_zones (
+	id SERIAL PRIMARY KEY,
+	name VARCHAR NOT NULL,
+	area JSONB NOT NULL,
+	min_order BIGINT NOT NULL DEFAULT 0,
+	delivery_fee BIGINT NOT NULL DEFAULT 0,
+	currency CHAR(3) NOT NULL,
+	travel_minutes INT NOT NULL DEFAULT 0,
+	is_active BOOLEAN NOT NULL DEFAULT true,
+	created_at TIMESTAMP NOT NULL DEFAULT now(),
+	updated_at TIMESTAMP NOT NULL DEFAULT now()
+);
+
+-- estimated_at is when an order should reach the customer, or be ready for
+-- pickup, as estimated when it was placed
+ALTER TABLE orders
+	ADD COLUMN delivery_zone_id INT REFERENCES delivery_zones(id) ON DELETE SET NULL,
+	ADD COLUMN estimated_at TIMESTAMP;
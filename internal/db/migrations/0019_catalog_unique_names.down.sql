@@ -0,0 +1,2 @@
+DROP INDEX food_name_key;
+DROP INDEX category_name_key;
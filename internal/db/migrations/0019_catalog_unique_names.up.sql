@@ -0,0 +1,18 @@
+-- Earlier versions only checked names in the repository, so live duplicates
+-- may exist. Keep the oldest row of each name and suffix the others with
+-- their id, leaving them for an admin to rename or delete.
+UPDATE category c SET name = c.name || ' (' || c.id || ')'
+WHERE c.deleted_at IS NULL AND EXISTS (
+	SELECT 1 FROM category o
+	WHERE lower(o.name) = lower(c.name) AND o.id < c.id AND o.deleted_at IS NULL
+);
+UPDATE food f SET name = f.name || ' (' || f.id || ')'
+WHERE f.deleted_at IS NULL AND EXISTS (
+	SELECT 1 FROM food o
+	WHERE o.category_id = f.category_id AND lower(o.name) = lower(f.name) AND o.id < f.id AND o.deleted_at IS NULL
+);
+-- Names are unique among categories, and among the foods of a category, that
+-- are not deleted. The repository checks this first to answer with a
+-- conflict; the indexes catch creates that race each other.
+CREATE UNIQUE INDEX category_name_key ON category (lower(name)) WHERE deleted_at IS NULL;
+CREATE UNIQUE INDEX food_name_key ON food (category_id, lower(name)) WHERE deleted_at IS NULL;
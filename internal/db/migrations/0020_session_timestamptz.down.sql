@@ -0,0 +1,10 @@
+ALTER TABLE refresh_tokens
+	ALTER COLUMN expires_at TYPE TIMESTAMP,
+	ALTER COLUMN created_at TYPE TIMESTAMP,
+	ALTER COLUMN used_at TYPE TIMESTAMP;
+
+ALTER TABLE sessions
+	ALTER COLUMN access_expires_at TYPE TIMESTAMP,
+	ALTER COLUMN created_at TYPE TIMESTAMP,
+	ALTER COLUMN last_refreshed_at TYPE TIMESTAMP,
+	ALTER COLUMN revoked_at TYPE TIMESTAMP;
@@ -0,0 +1,13 @@
+-- Expiry times are computed by the app and compared both by the app and by
+-- Postgres, so they must keep their time zone. Existing values are read in
+-- the time zone of the migrating session; they are short-lived anyway.
+ALTER TABLE sessions
+	ALTER COLUMN access_expires_at TYPE TIMESTAMPTZ,
+	ALTER COLUMN created_at TYPE TIMESTAMPTZ,
+	ALTER COLUMN last_refreshed_at TYPE TIMESTAMPTZ,
+	ALTER COLUMN revoked_at TYPE TIMESTAMPTZ;
+
+ALTER TABLE refresh_tokens
+	ALTER COLUMN expires_at TYPE TIMESTAMPTZ,
+	ALTER COLUMN created_at TYPE TIMESTAMPTZ,
+	ALTER COLUMN used_at TYPE TIMESTAMPTZ;
@@ -0,0 +1 @@
+DROP INDEX order_status_history_created_at_idx;
@@ -0,0 +1,3 @@
+-- Replaying order events looks back from the last event a client saw to the
+-- events recorded shortly before it, which may have committed after it.
+CREATE INDEX order_status_history_created_at_idx ON order_status_history (created_at);
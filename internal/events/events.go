@@ -0,0 +1,121 @@
+// Package events fans order events out to the parts of the service that push
+// them to clients. The default broker only reaches subscribers in this
+// process; with several replicas use the Postgres broker so that a change made
+// on one replica reaches clients connected to another.
+package events
+
+import (
+	"log"
+	"sync"
+	"time"
+)
+
+// Order event types
+const (
+	OrderCreated       = "order.created"
+	OrderStatusChanged = "order.status_changed"
+)
+
+// OrderEvent is a change of an order. ID is the id of the order's
+// order_status_history row, so events are ordered and can be replayed from
+// the database.
+type OrderEvent struct {
+	ID         int64     `json:"id"`
+	Type       string    `json:"type"`
+	OrderID    int       `json:"order_id"`
+	UserID     int       `json:"user_id"`
+	FromStatus *string   `json:"from_status"`
+	Status     string    `json:"status"`
+	ActorID    *int      `json:"actor_id"`
+	Reason     string    `json:"reason"`
+	At         time.Time `json:"at"`
+}
+
+// Broker delivers published events to every subscriber
+type Broker interface {
+	Publish(OrderEvent) error
+	Subscribe() *Subscription
+}
+
+// Subscription receives events on C until it is closed. C is also closed when
+// the subscriber falls too far behind; clients should then reconnect and
+// replay from the last event they saw.
+type Subscription struct {
+	C <-chan OrderEvent
+
+	ch   chan OrderEvent
+	once sync.Once
+	hub  *MemoryBroker
+}
+
+// Close stops the subscription
+func (s *Subscription) Close() {
+	s.hub.remove(s)
+}
+
+// subscriberBuffer is how many events a subscriber may lag behind before it
+// is dropped
+const subscriberBuffer = 64
+
+// MemoryBroker delivers events to subscribers in this process
+type MemoryBroker struct {
+	mu   sync.Mutex
+	subs map[*Subscription]struct{}
+}
+
+func NewMemoryBroker() *MemoryBroker {
+	return &MemoryBroker{subs: map[*Subscription]struct{}{}}
+}
+
+func (b *MemoryBroker) Publish(e OrderEvent) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for sub := range b.subs {
+		select {
+		case sub.ch <- e:
+		default:
+			delete(b.subs, sub)
+			sub.once.Do(func() { close(sub.ch) })
+		}
+	}
+	return nil
+}
+
+func (b *MemoryBroker) Subscribe() *Subscription {
+	ch := make(chan OrderEvent, subscriberBuffer)
+	sub := &Subscription{C: ch, ch: ch, hub: b}
+	b.mu.Lock()
+	b.subs[sub] = struct{}{}
+	b.mu.Unlock()
+	return sub
+}
+
+func (b *MemoryBroker) remove(sub *Subscription) {
+	b.mu.Lock()
+	delete(b.subs, sub)
+	b.mu.Unlock()
+	sub.once.Do(func() { close(sub.ch) })
+}
+
+var broker Broker = NewMemoryBroker()
+
+// SetBroker replaces the broker used by Publish and Subscribe. It must be
+// called before the server starts handling requests.
+func SetBroker(b Broker) {
+	broker = b
+}
+
+// Publish sends events to every subscriber. Events are published after the
+// change is committed, so a failure is only logged.
+func Publish(evs ...OrderEvent) {
+	for _, e := range evs {
+		if err := broker.Publish(e); err != nil {
+			log.Printf("Error publishing %s event for order %d: %v", e.Type, e.OrderID, err)
+		}
+	}
+}
+
+// Subscribe starts receiving every event published from now on
+func Subscribe() *Subscription {
+	return broker.Subscribe()
+}
@@ -0,0 +1,68 @@
+package events
+
+import (
+	"encoding/json"
+	"log"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+// notifyChannel is the Postgres channel order events are sent on
+const notifyChannel = "order_events"
+
+// PostgresBroker sends events through Postgres NOTIFY and delivers every
+// notification on the channel, including its own, to the subscribers in this
+// process
+type PostgresBroker struct {
+	db       *sqlx.DB
+	listener *pq.Listener
+	local    *MemoryBroker
+}
+
+// NewPostgresBroker starts listening on the order events channel. dsn is used
+// for the dedicated listening connection; db sends the notifications.
+func NewPostgresBroker(dsn string, db *sqlx.DB) (*PostgresBroker, error) {
+	listener := pq.NewListener(dsn, time.Second, time.Minute, func(ev pq.ListenerEventType, err error) {
+		if err != nil {
+			log.Println("Order events listener:", err)
+		}
+	})
+	if err := listener.Listen(notifyChannel); err != nil {
+		listener.Close()
+		return nil, err
+	}
+	b := &PostgresBroker{db: db, listener: listener, local: NewMemoryBroker()}
+	go b.run()
+	return b, nil
+}
+
+func (b *PostgresBroker) run() {
+	for n := range b.listener.Notify {
+		// A nil notification means the connection was re-established and
+		// notifications sent in the meantime were lost
+		if n == nil {
+			continue
+		}
+		var e OrderEvent
+		if err := json.Unmarshal([]byte(n.Extra), &e); err != nil {
+			log.Println("Invalid order event notification:", err)
+			continue
+		}
+		b.local.Publish(e)
+	}
+}
+
+func (b *PostgresBroker) Publish(e OrderEvent) error {
+	payload, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	_, err = b.db.Exec(`SELECT pg_notify($1, $2)`, notifyChannel, string(payload))
+	return err
+}
+
+func (b *PostgresBroker) Subscribe() *Subscription {
+	return b.local.Subscribe()
+}
@@ -0,0 +1,28 @@
+// Package geo holds the little geometry delivery needs, on WGS 84
+// coordinates in degrees.
+package geo
+
+import "math"
+
+// earthRadiusKm is the mean radius of the Earth
+const earthRadiusKm = 6371.0
+
+// Point is a position on the Earth
+type Point struct {
+	Lat float64 `json:"lat"`
+	Lng float64 `json:"lng"`
+}
+
+func radians(deg float64) float64 {
+	return deg * math.Pi / 180
+}
+
+// DistanceKm returns the great-circle distance between a and b in
+// kilometers, using the haversine formula
+func DistanceKm(a, b Point) float64 {
+	dLat := radians(b.Lat - a.Lat)
+	dLng := radians(b.Lng - a.Lng)
+	h := math.Sin(dLat/2)*math.Sin(dLat/2) +
+		math.Cos(radians(a.Lat))*math.Cos(radians(b.Lat))*math.Sin(dLng/2)*math.Sin(dLng/2)
+	return 2 * earthRadiusKm * math.Asin(math.Sqrt(h))
+}
@@ -0,0 +1,95 @@
+package geo
+
+import (
+	"encoding/json"
+	"errors"
+)
+
+var ErrInvalidShape = errors.New("area must be a GeoJSON Polygon or MultiPolygon")
+
+// Shape is an area made of polygons. Each polygon is a list of rings of
+// [longitude, latitude] positions, as in GeoJSON: the first ring is the
+// outline and any others are holes.
+type Shape [][][][2]float64
+
+// ParseShape reads a GeoJSON Polygon or MultiPolygon geometry. Rings must be
+// closed and have at least four positions.
+func ParseShape(data []byte) (Shape, error) {
+	var geometry struct {
+		Type        string          `json:"type"`
+		Coordinates json.RawMessage `json:"coordinates"`
+	}
+	if err := json.Unmarshal(data, &geometry); err != nil {
+		return nil, ErrInvalidShape
+	}
+	var shape Shape
+	switch geometry.Type {
+	case "Polygon":
+		var polygon [][][2]float64
+		if err := json.Unmarshal(geometry.Coordinates, &polygon); err != nil {
+			return nil, ErrInvalidShape
+		}
+		shape = Shape{polygon}
+	case "MultiPolygon":
+		if err := json.Unmarshal(geometry.Coordinates, &shape); err != nil {
+			return nil, ErrInvalidShape
+		}
+	default:
+		return nil, ErrInvalidShape
+	}
+	if len(shape) == 0 {
+		return nil, ErrInvalidShape
+	}
+	for _, polygon := range shape {
+		if len(polygon) == 0 {
+			return nil, ErrInvalidShape
+		}
+		for _, ring := range polygon {
+			if len(ring) < 4 || ring[0] != ring[len(ring)-1] {
+				return nil, ErrInvalidShape
+			}
+			for _, position := range ring {
+				if position[0] < -180 || position[0] > 180 || position[1] < -90 || position[1] > 90 {
+					return nil, ErrInvalidShape
+				}
+			}
+		}
+	}
+	return shape, nil
+}
+
+// Contains reports whether p lies inside the shape: inside the outline of
+// one of its polygons and outside that polygon's holes
+func (s Shape) Contains(p Point) bool {
+	for _, polygon := range s {
+		if !ringContains(polygon[0], p) {
+			continue
+		}
+		inHole := false
+		for _, hole := range polygon[1:] {
+			if ringContains(hole, p) {
+				inHole = true
+				break
+			}
+		}
+		if !inHole {
+			return true
+		}
+	}
+	return false
+}
+
+// ringContains casts a ray from p towards increasing longitude and counts
+// the ring edges it crosses; an odd count means p is inside. Areas are
+// assumed not to cross the antimeridian.
+func ringContains(ring [][2]float64, p Point) bool {
+	inside := false
+	for i, j := 0, len(ring)-1; i < len(ring); j, i = i, i+1 {
+		xi, yi := ring[i][0], ring[i][1]
+		xj, yj := ring[j][0], ring[j][1]
+		if (yi > p.Lat) != (yj > p.Lat) && p.Lng < (xj-xi)*(p.Lat-yi)/(yj-yi)+xi {
+			inside = !inside
+		}
+	}
+	return inside
+}
@@ -0,0 +1,70 @@
+package geo
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestParseShapeRejects(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+	}{
+		{"not json", `{`},
+		{"point", `{"type":"Point","coordinates":[69.2,41.3]}`},
+		{"no type", `{"coordinates":[[[0,0],[1,0],[1,1],[0,0]]]}`},
+		{"bad coordinates", `{"type":"Polygon","coordinates":"square"}`},
+		{"empty polygon", `{"type":"Polygon","coordinates":[]}`},
+		{"empty multipolygon", `{"type":"MultiPolygon","coordinates":[]}`},
+		{"multipolygon with an empty polygon", `{"type":"MultiPolygon","coordinates":[[]]}`},
+		{"too few positions", `{"type":"Polygon","coordinates":[[[0,0],[1,0],[0,0]]]}`},
+		{"unclosed ring", `{"type":"Polygon","coordinates":[[[0,0],[1,0],[1,1],[0,1]]]}`},
+		{"longitude out of range", `{"type":"Polygon","coordinates":[[[0,0],[181,0],[1,1],[0,0]]]}`},
+		{"latitude out of range", `{"type":"Polygon","coordinates":[[[0,0],[1,0],[1,91],[0,0]]]}`},
+	}
+	for _, tt := range tests {
+		if _, err := ParseShape([]byte(tt.data)); !errors.Is(err, ErrInvalidShape) {
+			t.Errorf("%s: ParseShape error = %v, want ErrInvalidShape", tt.name, err)
+		}
+	}
+}
+
+func TestShapeContains(t *testing.T) {
+	// A 10x10 square around (0, 0) with a 2x2 hole in the middle, and a
+	// second square further east
+	polygon := `{"type":"Polygon","coordinates":[
+		[[-5,-5],[5,-5],[5,5],[-5,5],[-5,-5]],
+		[[-1,-1],[1,-1],[1,1],[-1,1],[-1,-1]]
+	]}`
+	multi := `{"type":"MultiPolygon","coordinates":[
+		[[[-5,-5],[5,-5],[5,5],[-5,5],[-5,-5]],[[-1,-1],[1,-1],[1,1],[-1,1],[-1,-1]]],
+		[[[20,0],[30,0],[30,10],[20,10],[20,0]]]
+	]}`
+	tests := []struct {
+		name  string
+		shape string
+		point Point
+		want  bool
+	}{
+		{"polygon inside", polygon, Point{Lat: 3, Lng: 3}, true},
+		{"polygon in hole", polygon, Point{Lat: 0, Lng: 0}, false},
+		{"polygon outside", polygon, Point{Lat: 6, Lng: 0}, false},
+		{"polygon between hole and outline", polygon, Point{Lat: 0, Lng: -3}, true},
+		{"multipolygon first", multi, Point{Lat: -4, Lng: 4}, true},
+		{"multipolygon first hole", multi, Point{Lat: 0.5, Lng: 0.5}, false},
+		{"multipolygon second", multi, Point{Lat: 5, Lng: 25}, true},
+		{"multipolygon between", multi, Point{Lat: 5, Lng: 15}, false},
+		// Positions are [longitude, latitude], so the second square spans
+		// latitudes 0..10 only
+		{"multipolygon swapped axes", multi, Point{Lat: 25, Lng: 5}, false},
+	}
+	for _, tt := range tests {
+		shape, err := ParseShape([]byte(tt.shape))
+		if err != nil {
+			t.Fatalf("%s: ParseShape: %v", tt.name, err)
+		}
+		if got := shape.Contains(tt.point); got != tt.want {
+			t.Errorf("%s: Contains(%+v) = %v, want %v", tt.name, tt.point, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,183 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/Anwarjondev/fast-food/internal/repository"
+	"github.com/gin-gonic/gin"
+)
+
+// AddressRequest represents the request body for creating or updating an
+// address. latitude and longitude place it on the map for delivery fees;
+// courier_notes are shown to the courier. is_default true makes it the
+// address delivery orders use when they do not name one; when left out of an
+// update the default stays as it is.
+type AddressRequest struct {
+	Label        string   `json:"label" binding:"max=50"`
+	Line1        string   `json:"line1" binding:"required,max=200"`
+	Line2        string   `json:"line2" binding:"max=200"`
+	City         string   `json:"city" binding:"required,max=100"`
+	PostalCode   string   `json:"postal_code" binding:"max=20"`
+	Phone        string   `json:"phone" binding:"required,max=30"`
+	Latitude     *float64 `json:"latitude" binding:"required,latitude"`
+	Longitude    *float64 `json:"longitude" binding:"required,longitude"`
+	CourierNotes string   `json:"courier_notes" binding:"max=500"`
+	IsDefault    *bool    `json:"is_default"`
+}
+
+func (r AddressRequest) address(userID, id int) repository.Address {
+	return repository.Address{
+		ID:           id,
+		UserID:       userID,
+		Label:        r.Label,
+		Line1:        r.Line1,
+		Line2:        r.Line2,
+		City:         r.City,
+		PostalCode:   r.PostalCode,
+		Phone:        r.Phone,
+		Latitude:     *r.Latitude,
+		Longitude:    *r.Longitude,
+		CourierNotes: r.CourierNotes,
+		IsDefault:    r.IsDefault != nil && *r.IsDefault,
+	}
+}
+
+// GetAddresses godoc
+// @Summary List addresses
+// @Description List the user's addresses, the default one first
+// @Tags addresses
+// @Security BearerAuth
+// @Produce json
+// @Success 200 {object} []repository.Address
+// @Router /addresses [get]
+func GetAddresses(c *gin.Context) {
+	addresses, err := repository.GetAddresses(c.GetInt("user_id"))
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, addresses)
+}
+
+// GetAddress godoc
+// @Summary Get address
+// @Description Get one of the user's addresses
+// @Tags addresses
+// @Security BearerAuth
+// @Produce json
+// @Param id path int true "Address ID"
+// @Success 200 {object} repository.Address
+// @Failure 404 {object} response.Error
+// @Router /addresses/{id} [get]
+func GetAddress(c *gin.Context) {
+	id, ok := pathID(c, "id")
+	if !ok {
+		return
+	}
+	address, err := repository.GetAddress(c.GetInt("user_id"), id)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, address)
+}
+
+// CreateAddress godoc
+// @Summary Create address
+// @Description Add an address to the user's address book. The first address becomes the default.
+// @Tags addresses
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param request body AddressRequest true "Address"
+// @Success 201 {object} repository.Address
+// @Failure 400 {object} response.Error
+// @Router /addresses [post]
+func CreateAddress(c *gin.Context) {
+	var req AddressRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+	address, err := repository.CreateAddress(req.address(c.GetInt("user_id"), 0))
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, address)
+}
+
+// UpdateAddress godoc
+// @Summary Update address
+// @Description Change one of the user's addresses; orders already placed keep the address they were placed with. is_default is only changed when sent, and the default address cannot be unset this way; make another address the default instead (409).
+// @Tags addresses
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param id path int true "Address ID"
+// @Param request body AddressRequest true "Address"
+// @Success 200 {object} repository.Address
+// @Failure 400 {object} response.Error
+// @Failure 404 {object} response.Error
+// @Failure 409 {object} response.Error
+// @Router /addresses/{id} [put]
+func UpdateAddress(c *gin.Context) {
+	id, ok := pathID(c, "id")
+	if !ok {
+		return
+	}
+	var req AddressRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+	address, err := repository.UpdateAddress(req.address(c.GetInt("user_id"), id), req.IsDefault)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, address)
+}
+
+// SetDefaultAddress godoc
+// @Summary Set default address
+// @Description Make one of the user's addresses the default for delivery orders
+// @Tags addresses
+// @Security BearerAuth
+// @Produce json
+// @Param id path int true "Address ID"
+// @Success 200 {object} repository.Address
+// @Failure 404 {object} response.Error
+// @Router /addresses/{id}/default [post]
+func SetDefaultAddress(c *gin.Context) {
+	id, ok := pathID(c, "id")
+	if !ok {
+		return
+	}
+	address, err := repository.SetDefaultAddress(c.GetInt("user_id"), id)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, address)
+}
+
+// DeleteAddress godoc
+// @Summary Delete address
+// @Description Remove one of the user's addresses. When it was the default, the newest other address becomes the default.
+// @Tags addresses
+// @Security BearerAuth
+// @Produce json
+// @Param id path int true "Address ID"
+// @Success 200 {object} map[string]interface{}
+// @Failure 404 {object} response.Error
+// @Router /addresses/{id} [delete]
+func DeleteAddress(c *gin.Context) {
+	id, ok := pathID(c, "id")
+	if !ok {
+		return
+	}
+	if err := repository.DeleteAddress(c.GetInt("user_id"), id); err != nil {
+		respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Address deleted"})
+}
@@ -2,32 +2,66 @@ package handlers
 
 import (
 	"fmt"
+	"log"
 	"net/http"
-	"time"
 
 	"github.com/Anwarjondev/fast-food/config"
-	"github.com/Anwarjondev/fast-food/internal/db"
 	"github.com/Anwarjondev/fast-food/internal/repository"
+	"github.com/Anwarjondev/fast-food/internal/response"
+	"github.com/Anwarjondev/fast-food/internal/token"
 	"github.com/Anwarjondev/fast-food/internal/utils"
 	"github.com/gin-gonic/gin"
-	"github.com/google/uuid"
 )
 
 var appConfig config.Config
 
+// jwtManager signs access tokens when AUTH_MODE=jwt, and is nil otherwise
+var jwtManager *token.Manager
+
 func SetConfig(c config.Config) {
 	appConfig = c
 }
 
+func SetTokenManager(m *token.Manager) {
+	jwtManager = m
+}
+
+// signAccessToken replaces the opaque access token of a session with a JWT
+// when running in JWT mode
+func signAccessToken(tokens *repository.TokenPair) error {
+	if jwtManager == nil {
+		return nil
+	}
+	roles, err := repository.GetUserRoles(tokens.UserID)
+	if err != nil {
+		return err
+	}
+	tokens.AccessToken, err = jwtManager.Sign(tokens.UserID, roles, tokens.SessionID, tokens.AccessExpiresAt)
+	return err
+}
+
+// sendCode generates a code for purpose, stores it and emails it to the user
+func sendCode(userID int, email, purpose string) error {
+	code, err := utils.GenerateCode()
+	if err != nil {
+		return err
+	}
+	if err := repository.SaveCode(userID, purpose, code, appConfig.CodeTTL); err != nil {
+		return err
+	}
+	return utils.SendEmailCode(email, code, appConfig.SMPTHost, appConfig.SMTPPort, appConfig.EMAILSender, appConfig.EMAILPassword)
+}
+
 // RegisterRequest represents the request body for user registration
 type RegisterRequest struct {
 	Email    string `json:"email"`
-	Password string `json:"password"`
+	Password string `json:"password" binding:"required,max=72"`
 }
 
 // ConfirmRequest represents the request body for user confirmation
 type ConfirmRequest struct {
-	Code int `json:"code"`
+	Email string `json:"email" binding:"required"`
+	Code  string `json:"code" binding:"required,len=6,numeric"`
 }
 
 // LoginRequest represents the request body for user login
@@ -36,6 +70,10 @@ type LoginRequest struct {
 	Password string `json:"password"`
 }
 
+// RefreshTokenRequest represents the request body for refreshing tokens
+type RefreshTokenRequest struct {
+	RefreshToken string `json:"refresh_token" binding:"required"`
+}
 
 // ForgotPasswordRequest represents the request body for password reset request
 type ForgotPasswordRequest struct {
@@ -44,8 +82,9 @@ type ForgotPasswordRequest struct {
 
 // ResetPasswordRequest represents the request body for password reset
 type ResetPasswordRequest struct {
-	Email    string `json:"email"`
-	Password string `json:"password"`
+	Email    string `json:"email" binding:"required"`
+	Code     string `json:"code" binding:"required,len=6,numeric"`
+	Password string `json:"password" binding:"required,max=72"`
 }
 
 // ResendCodeRequest represents the request body for resending confirmation code
@@ -64,39 +103,32 @@ type ResendCodeRequest struct {
 // @Router /register [post]
 func Register(c *gin.Context) {
 	var req RegisterRequest
-	if err := c.BindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	if !bindJSON(c, &req) {
 		return
 	}
-	userID, err := repository.CreateUser(req.Email, req.Password)
+	passwordHash, err := utils.HashPassword(req.Password, appConfig.PasswordCost)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		respondError(c, err)
 		return
 	}
-	code := utils.GenerateCode()
-	repository.SaveConfirmaion(userID, code)
-
-	// Log the code for debugging
-	fmt.Printf("Generated code for user %d: %d\n", userID, code)
-
-	err = utils.SendEmailCode(req.Email, code, appConfig.SMPTHost, appConfig.SMTPPort, appConfig.EMAILSender, appConfig.EMAILPassword)
+	userID, err := repository.CreateUser(req.Email, passwordHash)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	err = sendCode(userID, req.Email, repository.CodePurposeSignup)
 	if err != nil {
 		fmt.Printf("Failed to send email: %v\n", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Failed to send confirmation email: %v", err)})
+		response.Abort(c, http.StatusBadGateway, "email_failed", "Failed to send confirmation email")
 		return
 	}
 
-	go func() {
-		time.Sleep(time.Minute)
-		repository.MarkCodeAsPassed(userID)
-	}()
-
 	c.JSON(http.StatusOK, gin.H{"message": "Code sent to email"})
 }
 
 // Confirm godoc
 // @Summary Confirm user registration
-// @Description Confirm user registration with code
+// @Description Confirm user registration with the email and the code sent to it
 // @Tags auth
 // @Accept json
 // @Produce json
@@ -105,27 +137,25 @@ func Register(c *gin.Context) {
 // @Router /confirm [post]
 func Confirm(c *gin.Context) {
 	var req ConfirmRequest
-	if err := c.BindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	if !bindJSON(c, &req) {
 		return
 	}
 
-	// Find user ID by code
-	userID, err := repository.GetUserIDByCode(req.Code)
+	userID, err := repository.GetUserIDByEmail(req.Email)
 	if err != nil {
-		fmt.Println(err)
-		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		respondError(c, err)
 		return
 	}
 
-	ok, err := repository.CheckCode(userID, req.Code)
-	if err != nil || !ok {
-		fmt.Println(err)
-		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+	err = repository.VerifyCode(userID, repository.CodePurposeSignup, req.Code, appConfig.CodeMaxAttempts)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	if err := repository.ActiveUser(userID); err != nil {
+		respondError(c, err)
 		return
 	}
-	repository.MarkCodeAsPassed(userID)
-	repository.ActiveUser(userID)
 	c.JSON(http.StatusOK, gin.H{"message": "account verified"})
 }
 
@@ -140,39 +170,83 @@ func Confirm(c *gin.Context) {
 // @Router /login [post]
 func Login(c *gin.Context) {
 	var req LoginRequest
-	token := uuid.New().String()
+	if !bindJSON(c, &req) {
+		return
+	}
+	UserID, err := repository.LoginUser(req.Email, req.Password, appConfig.PasswordCost)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	tokens, err := repository.CreateSession(UserID, c.Request.UserAgent(), c.ClientIP(), appConfig.AccessTokenTTL, appConfig.RefreshTokenTTL)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	if err := signAccessToken(&tokens); err != nil {
+		respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"message":            "login successful",
+		"Token":              tokens.AccessToken,
+		"access_expires_at":  tokens.AccessExpiresAt,
+		"refresh_token":      tokens.RefreshToken,
+		"refresh_expires_at": tokens.RefreshExpiresAt,
+	})
+}
 
-	if err := c.BindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+// RefreshToken godoc
+// @Summary Refresh tokens
+// @Description Exchange a refresh token for a new access and refresh token. Each refresh token can be used once.
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param request body RefreshTokenRequest true "Refresh token"
+// @Success 200 {object} repository.TokenPair
+// @Router /token/refresh [post]
+func RefreshToken(c *gin.Context) {
+	var req RefreshTokenRequest
+	if !bindJSON(c, &req) {
 		return
 	}
-	UserID, err := repository.LoginUser(req.Email, req.Password)
+	tokens, err := repository.RefreshSession(req.RefreshToken, appConfig.AccessTokenTTL, appConfig.RefreshTokenTTL)
 	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		respondError(c, err)
+		return
+	}
+	if err := signAccessToken(&tokens); err != nil {
+		respondError(c, err)
 		return
 	}
-	err = repository.SaveToken(UserID, token)
-	c.JSON(http.StatusOK, gin.H{"message": "login successful", "Token": token})
+	c.JSON(http.StatusOK, tokens)
 }
 
 // Logout godoc
 // @Summary User logout
-// @Description Logout user and invalidate token
+// @Description Logout the current session, or every session of the user with all=true
 // @Tags auth
 // @Security BearerAuth
+// @Param all query bool false "Log out on every device"
 // @Success 200 {object} map[string]interface{}
 // @Router /logout [post]
 func Logout(c *gin.Context) {
-	// Get user ID from the context (set by AuthMiddleware)
+	// Get user and session from the context (set by AuthMiddleware)
 	userID := c.GetInt("user_id")
-	if userID == 0 {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+	sessionID := c.GetInt("session_id")
+	if userID == 0 || sessionID == 0 {
+		response.Abort(c, http.StatusUnauthorized, "unauthorized", "Unauthorized")
 		return
 	}
 
-	err := repository.LogoutUser(userID)
+	var err error
+	if c.Query("all") == "true" {
+		err = repository.RevokeUserSessions(userID)
+	} else {
+		err = repository.RevokeSession(sessionID)
+	}
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		respondError(c, err)
 		return
 	}
 	c.JSON(http.StatusOK, gin.H{"message": "logout successful"})
@@ -189,26 +263,18 @@ func Logout(c *gin.Context) {
 // @Router /forgot-password [post]
 func ForgotPassword(c *gin.Context) {
 	var req ForgotPasswordRequest
-	if err := c.BindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	if !bindJSON(c, &req) {
 		return
 	}
-	var userID int
-	err := db.DB.Get(&userID, `Select id from users where email = $1`, req.Email)
+	userID, err := repository.GetUserIDByEmail(req.Email)
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		respondError(c, err)
 		return
 	}
 
-	code := utils.GenerateCode()
-	err = utils.SendEmailCode(req.Email, code, appConfig.SMPTHost, appConfig.SMTPPort, appConfig.EMAILSender, appConfig.EMAILPassword)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		return
-	}
-	err = repository.SaveConfirmaion(userID, code)
+	err = sendCode(userID, req.Email, repository.CodePurposeReset)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		respondError(c, err)
 		return
 	}
 
@@ -217,7 +283,7 @@ func ForgotPassword(c *gin.Context) {
 
 // ResetPassword godoc
 // @Summary Reset password
-// @Description Reset password with code
+// @Description Reset password with the code sent by forgot-password. Logs the user out on every device.
 // @Tags auth
 // @Accept json
 // @Produce json
@@ -226,26 +292,36 @@ func ForgotPassword(c *gin.Context) {
 // @Router /reset-password [post]
 func ResetPassword(c *gin.Context) {
 	var req ResetPasswordRequest
-	if err := c.BindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	if !bindJSON(c, &req) {
 		return
 	}
 
 	// Get user ID from email
-	var userID int
-	err := db.DB.Get(&userID, `Select id from users where email = $1`, req.Email)
+	userID, err := repository.GetUserIDByEmail(req.Email)
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		respondError(c, err)
 		return
 	}
 
-	// Update the password
-	err = repository.UpdatePassword(userID, req.Password)
+	// Check the code sent by ForgotPassword, update the password and log out
+	// every session
+	passwordHash, err := utils.HashPassword(req.Password, appConfig.PasswordCost)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	err = repository.ResetPassword(userID, req.Code, appConfig.CodeMaxAttempts, passwordHash)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update password"})
+		respondError(c, err)
 		return
 	}
 
+	// The password is already changed, so a failed notification is only logged
+	err = utils.SendPasswordChangedEmail(req.Email, appConfig.SMPTHost, appConfig.SMTPPort, appConfig.EMAILSender, appConfig.EMAILPassword)
+	if err != nil {
+		log.Printf("Sending password changed email to user %d: %v", userID, err)
+	}
+
 	c.JSON(http.StatusOK, gin.H{"message": "Password reset successful"})
 }
 
@@ -260,27 +336,19 @@ func ResetPassword(c *gin.Context) {
 // @Router /resend-code [post]
 func ResendCode(c *gin.Context) {
 	var req ResendCodeRequest
-	if err := c.BindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	if !bindJSON(c, &req) {
 		return
 	}
 
 	// Get user ID from email
-	var userID int
-	err := db.DB.Get(&userID, `Select id from users where email = $1`, req.Email)
-	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
-		return
-	}
-	code := utils.GenerateCode()
-	err = repository.SaveConfirmaion(userID, code)
+	userID, err := repository.GetUserIDByEmail(req.Email)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		respondError(c, err)
 		return
 	}
-	err = utils.SendEmailCode(req.Email, code, appConfig.SMPTHost, appConfig.SMTPPort, appConfig.EMAILSender, appConfig.EMAILPassword)
+	err = sendCode(userID, req.Email, repository.CodePurposeSignup)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		respondError(c, err)
 		return
 	}
 	c.JSON(http.StatusOK, gin.H{"message": "New code sent to email"})
@@ -0,0 +1,209 @@
+package handlers
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+
+	"github.com/Anwarjondev/fast-food/internal/repository"
+	"github.com/gin-gonic/gin"
+)
+
+// CartItemRequest represents the request body for adding a food to the cart.
+// The count limit is repository.MaxItemCount, as for order lines.
+type CartItemRequest struct {
+	FoodID int `json:"food_id" binding:"gt=0"`
+	Count  int `json:"count" binding:"item_count"`
+}
+
+// CartCountRequest represents the request body for changing how many of a
+// food the cart holds
+type CartCountRequest struct {
+	Count int `json:"count" binding:"item_count"`
+}
+
+// CheckoutRequest represents the optional request body for checking out the
+// cart. The payment and fulfillment fields work as on CreateOrderInput.
+type CheckoutRequest struct {
+	PromoCode     string `json:"promo_code" binding:"max=50"`
+	PaymentMethod string `json:"payment_method" binding:"max=30"`
+	PaymentToken  string `json:"payment_token" binding:"max=200"`
+	Fulfillment   string `json:"fulfillment" binding:"omitempty,oneof=delivery pickup"`
+	AddressID     int    `json:"address_id" binding:"gte=0"`
+}
+
+// respondCart replies with the user's current cart
+func respondCart(c *gin.Context, userID int) {
+	cart, err := repository.GetCart(userID, appConfig.Currency)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, cart)
+}
+
+// GetCart godoc
+// @Summary Get cart
+// @Description Get the user's cart with current prices and stock. Items that were deleted or are short on stock are marked unavailable and left out of the total.
+// @Tags cart
+// @Security BearerAuth
+// @Produce json
+// @Success 200 {object} repository.Cart
+// @Router /cart [get]
+func GetCart(c *gin.Context) {
+	respondCart(c, c.GetInt("user_id"))
+}
+
+// AddCartItem godoc
+// @Summary Add to cart
+// @Description Add a food to the cart, on top of what the cart already holds of it. Like an order, the cart holds a limited number of each food and of different foods; going over either limit fails with 422 and a message that gives the limit.
+// @Tags cart
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param request body CartItemRequest true "Food and count"
+// @Success 200 {object} repository.Cart
+// @Failure 404 {object} response.Error
+// @Failure 422 {object} response.Error
+// @Router /cart/items [post]
+func AddCartItem(c *gin.Context) {
+	userID := c.GetInt("user_id")
+	var req CartItemRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+	if err := repository.AddCartItem(userID, req.FoodID, req.Count); err != nil {
+		respondError(c, err)
+		return
+	}
+	respondCart(c, userID)
+}
+
+// UpdateCartItem godoc
+// @Summary Update cart item
+// @Description Set how many of a food the cart holds. Fails with 422 when the cart would hold more different foods than an order may.
+// @Tags cart
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param food_id path int true "Food ID"
+// @Param request body CartCountRequest true "Count"
+// @Success 200 {object} repository.Cart
+// @Failure 404 {object} response.Error
+// @Failure 422 {object} response.Error
+// @Router /cart/items/{food_id} [put]
+func UpdateCartItem(c *gin.Context) {
+	userID := c.GetInt("user_id")
+	foodID, ok := pathID(c, "food_id")
+	if !ok {
+		return
+	}
+	var req CartCountRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+	if err := repository.SetCartItem(userID, foodID, req.Count); err != nil {
+		respondError(c, err)
+		return
+	}
+	respondCart(c, userID)
+}
+
+// RemoveCartItem godoc
+// @Summary Remove from cart
+// @Description Remove a food from the cart
+// @Tags cart
+// @Security BearerAuth
+// @Produce json
+// @Param food_id path int true "Food ID"
+// @Success 200 {object} repository.Cart
+// @Failure 404 {object} response.Error
+// @Router /cart/items/{food_id} [delete]
+func RemoveCartItem(c *gin.Context) {
+	userID := c.GetInt("user_id")
+	foodID, ok := pathID(c, "food_id")
+	if !ok {
+		return
+	}
+	if err := repository.RemoveCartItem(userID, foodID); err != nil {
+		respondError(c, err)
+		return
+	}
+	respondCart(c, userID)
+}
+
+// ClearCart godoc
+// @Summary Clear cart
+// @Description Remove every food from the cart
+// @Tags cart
+// @Security BearerAuth
+// @Produce json
+// @Success 200 {object} repository.Cart
+// @Router /cart [delete]
+func ClearCart(c *gin.Context) {
+	userID := c.GetInt("user_id")
+	if err := repository.ClearCart(userID); err != nil {
+		respondError(c, err)
+		return
+	}
+	respondCart(c, userID)
+}
+
+// Checkout godoc
+// @Summary Checkout cart
+// @Description Place an order for everything in the cart, authorize its payment and empty the cart. Fails like POST /orders, keeping the cart; when the payment is declined or fails the cart is filled again.
+// @Tags cart
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param request body CheckoutRequest false "Promo code and payment"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} response.Error
+// @Failure 402 {object} response.Error
+// @Failure 409 {object} response.Error
+// @Failure 502 {object} response.Error
+// @Router /cart/checkout [post]
+func Checkout(c *gin.Context) {
+	userID := c.GetInt("user_id")
+	var req CheckoutRequest
+	if c.Request.ContentLength != 0 && !bindJSON(c, &req) {
+		return
+	}
+	provider, ok := paymentProvider(c, req.PaymentMethod)
+	if !ok {
+		return
+	}
+	order, err := repository.CheckoutCart(userID, repository.NewOrder{
+		PromoCode:     req.PromoCode,
+		PaymentMethod: provider.Name(),
+		Fulfillment:   req.Fulfillment,
+		AddressID:     req.AddressID,
+	}, orderCharges(), kitchenTimes())
+	if err != nil {
+		var items []repository.CartItem
+		if cart, err := repository.GetCart(userID, appConfig.Currency); err == nil {
+			items = cart.Items
+		}
+		respondOrderError(c, err, func(foodID int, field string) string {
+			for i, item := range items {
+				if item.FoodID == foodID {
+					return fmt.Sprintf("items[%d].%s", i, field)
+				}
+			}
+			return "items"
+		})
+		return
+	}
+	order, paymentStatus, err := authorizeOrder(order, provider, req.PaymentToken)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	if order.Status == repository.StatusCanceled {
+		// Let the customer try again with another payment
+		if err := repository.RefillCart(userID, order.ID); err != nil {
+			log.Printf("Refilling cart of user %d from order %d: %v", userID, order.ID, err)
+		}
+	}
+	respondPlacedOrder(c, order, paymentStatus)
+}
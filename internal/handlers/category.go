@@ -2,12 +2,24 @@ package handlers
 
 import (
 	"net/http"
-	"strconv"
 
 	"github.com/Anwarjondev/fast-food/internal/repository"
 	"github.com/gin-gonic/gin"
 )
 
+// CategoryRequest represents the request body for creating or updating a
+// category. tax_rate is a percentage; without it the category's foods are
+// taxed at the default rate.
+type CategoryRequest struct {
+	Name    string   `json:"name" binding:"required,max=100"`
+	TaxRate *float64 `json:"tax_rate" binding:"omitempty,gte=0,max=100"`
+}
+
+// ReorderRequest represents the request body for reordering categories or foods
+type ReorderRequest struct {
+	IDs []int `json:"ids" binding:"required,min=1,dive,gt=0"`
+}
+
 // GetAllCategories godoc
 // @Summary Get all categories
 // @Description Get list of all food categories
@@ -19,7 +31,7 @@ import (
 func GetAllCategories(c *gin.Context) {
 	categories, err := repository.GetAllCategories()
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		respondError(c, err)
 		return
 	}
 	c.JSON(http.StatusOK, categories)
@@ -35,16 +47,108 @@ func GetAllCategories(c *gin.Context) {
 // @Success 200 {object} repository.Category
 // @Router /categories/{id} [get]
 func GetCategoryByID(c *gin.Context) {
-	idstr := c.Param("id")
-	id, err := strconv.Atoi(idstr)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	id, ok := pathID(c, "id")
+	if !ok {
 		return
 	}
 	category, err := repository.GetCategoryById(id)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		respondError(c, err)
 		return
 	}
 	c.JSON(http.StatusOK, category)
 }
+
+// CreateCategory godoc
+// @Summary Create category
+// @Description Create a new food category (admin only)
+// @Tags admin
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param request body CategoryRequest true "Category"
+// @Success 201 {object} repository.Category
+// @Router /admin/categories [post]
+func CreateCategory(c *gin.Context) {
+	var req CategoryRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+	category, err := repository.CreateCategory(req.Name, req.TaxRate)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, category)
+}
+
+// UpdateCategory godoc
+// @Summary Update category
+// @Description Rename a food category or change its tax rate (admin only)
+// @Tags admin
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param id path int true "Category ID"
+// @Param request body CategoryRequest true "Category"
+// @Success 200 {object} repository.Category
+// @Router /admin/categories/{id} [put]
+func UpdateCategory(c *gin.Context) {
+	id, ok := pathID(c, "id")
+	if !ok {
+		return
+	}
+	var req CategoryRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+	category, err := repository.UpdateCategory(id, req.Name, req.TaxRate)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, category)
+}
+
+// DeleteCategory godoc
+// @Summary Delete category
+// @Description Soft-delete an empty food category (admin only)
+// @Tags admin
+// @Security BearerAuth
+// @Produce json
+// @Param id path int true "Category ID"
+// @Success 200 {object} map[string]interface{}
+// @Router /admin/categories/{id} [delete]
+func DeleteCategory(c *gin.Context) {
+	id, ok := pathID(c, "id")
+	if !ok {
+		return
+	}
+	if err := repository.DeleteCategory(id); err != nil {
+		respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Category deleted"})
+}
+
+// ReorderCategories godoc
+// @Summary Reorder categories
+// @Description Set the display order of categories (admin only)
+// @Tags admin
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param request body ReorderRequest true "Category IDs in display order"
+// @Success 200 {object} map[string]interface{}
+// @Router /admin/categories/order [put]
+func ReorderCategories(c *gin.Context) {
+	var req ReorderRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+	if err := repository.ReorderCategories(req.IDs); err != nil {
+		respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Categories reordered"})
+}
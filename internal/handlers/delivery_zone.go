@@ -0,0 +1,170 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/Anwarjondev/fast-food/internal/geo"
+	"github.com/Anwarjondev/fast-food/internal/money"
+	"github.com/Anwarjondev/fast-food/internal/repository"
+	"github.com/Anwarjondev/fast-food/internal/response"
+	"github.com/gin-gonic/gin"
+	"github.com/jmoiron/sqlx/types"
+)
+
+// DeliveryZoneRequest represents the request body for creating or updating a
+// delivery zone. area is a GeoJSON Polygon or MultiPolygon geometry with
+// [longitude, latitude] positions. min_order and delivery_fee are in minor
+// units of currency, which defaults to the configured one.
+type DeliveryZoneRequest struct {
+	Name          string          `json:"name" binding:"required,max=100"`
+	Area          json.RawMessage `json:"area" binding:"required" swaggertype:"object"`
+	MinOrder      int64           `json:"min_order" binding:"gte=0"`
+	DeliveryFee   int64           `json:"delivery_fee" binding:"gte=0"`
+	Currency      string          `json:"currency" binding:"omitempty,iso4217"`
+	TravelMinutes int             `json:"travel_minutes" binding:"gte=0,max=600"`
+	IsActive      *bool           `json:"is_active"`
+}
+
+func (r DeliveryZoneRequest) zone(id int) repository.DeliveryZone {
+	currency := currencyOrDefault(r.Currency)
+	return repository.DeliveryZone{
+		ID:            id,
+		Name:          r.Name,
+		Area:          types.JSONText(r.Area),
+		MinOrder:      money.New(r.MinOrder, currency),
+		DeliveryFee:   money.New(r.DeliveryFee, currency),
+		TravelMinutes: r.TravelMinutes,
+		IsActive:      r.IsActive == nil || *r.IsActive,
+	}
+}
+
+// bindDeliveryZone binds a delivery zone request and checks its area. On
+// failure it writes the error response and returns false.
+func bindDeliveryZone(c *gin.Context, req *DeliveryZoneRequest) bool {
+	if !bindJSON(c, req) {
+		return false
+	}
+	if _, err := geo.ParseShape(req.Area); err != nil {
+		response.Abort(c, http.StatusBadRequest, "validation_failed", "Request body is invalid",
+			response.FieldError{Field: "area", Message: "must be a GeoJSON Polygon or MultiPolygon with closed rings"})
+		return false
+	}
+	return true
+}
+
+// GetDeliveryZones godoc
+// @Summary List delivery zones
+// @Description List delivery zones with their areas (admin only)
+// @Tags admin
+// @Security BearerAuth
+// @Produce json
+// @Success 200 {object} []repository.DeliveryZone
+// @Router /admin/delivery-zones [get]
+func GetDeliveryZones(c *gin.Context) {
+	zones, err := repository.GetDeliveryZones()
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, zones)
+}
+
+// GetDeliveryZone godoc
+// @Summary Get delivery zone
+// @Description Get a delivery zone (admin only)
+// @Tags admin
+// @Security BearerAuth
+// @Produce json
+// @Param id path int true "Delivery zone ID"
+// @Success 200 {object} repository.DeliveryZone
+// @Failure 404 {object} response.Error
+// @Router /admin/delivery-zones/{id} [get]
+func GetDeliveryZone(c *gin.Context) {
+	id, ok := pathID(c, "id")
+	if !ok {
+		return
+	}
+	zone, err := repository.GetDeliveryZone(id)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, zone)
+}
+
+// CreateDeliveryZone godoc
+// @Summary Create delivery zone
+// @Description Add an area that is delivered to. Once any zone is active, delivery orders outside every active zone are refused. (admin only)
+// @Tags admin
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param request body DeliveryZoneRequest true "Delivery zone"
+// @Success 201 {object} repository.DeliveryZone
+// @Failure 400 {object} response.Error
+// @Router /admin/delivery-zones [post]
+func CreateDeliveryZone(c *gin.Context) {
+	var req DeliveryZoneRequest
+	if !bindDeliveryZone(c, &req) {
+		return
+	}
+	zone, err := repository.CreateDeliveryZone(req.zone(0))
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, zone)
+}
+
+// UpdateDeliveryZone godoc
+// @Summary Update delivery zone
+// @Description Change a delivery zone; orders already placed keep their delivery fee (admin only)
+// @Tags admin
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param id path int true "Delivery zone ID"
+// @Param request body DeliveryZoneRequest true "Delivery zone"
+// @Success 200 {object} repository.DeliveryZone
+// @Failure 400 {object} response.Error
+// @Failure 404 {object} response.Error
+// @Router /admin/delivery-zones/{id} [put]
+func UpdateDeliveryZone(c *gin.Context) {
+	id, ok := pathID(c, "id")
+	if !ok {
+		return
+	}
+	var req DeliveryZoneRequest
+	if !bindDeliveryZone(c, &req) {
+		return
+	}
+	zone, err := repository.UpdateDeliveryZone(req.zone(id))
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, zone)
+}
+
+// DeleteDeliveryZone godoc
+// @Summary Delete delivery zone
+// @Description Delete a delivery zone (admin only)
+// @Tags admin
+// @Security BearerAuth
+// @Produce json
+// @Param id path int true "Delivery zone ID"
+// @Success 200 {object} map[string]interface{}
+// @Failure 404 {object} response.Error
+// @Router /admin/delivery-zones/{id} [delete]
+func DeleteDeliveryZone(c *gin.Context) {
+	id, ok := pathID(c, "id")
+	if !ok {
+		return
+	}
+	if err := repository.DeleteDeliveryZone(id); err != nil {
+		respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Delivery zone deleted"})
+}
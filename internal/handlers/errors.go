@@ -0,0 +1,224 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/Anwarjondev/fast-food/internal/payment"
+	"github.com/Anwarjondev/fast-food/internal/repository"
+	"github.com/Anwarjondev/fast-food/internal/response"
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+)
+
+// apiErrors maps repository and payment errors to HTTP statuses and error codes
+var apiErrors = []struct {
+	err    error
+	status int
+	code   string
+}{
+	{repository.ErrCategoryNotFound, http.StatusNotFound, "category_not_found"},
+	{repository.ErrCategoryExists, http.StatusConflict, "category_exists"},
+	{repository.ErrCategoryNotEmpty, http.StatusConflict, "category_not_empty"},
+	{repository.ErrFoodNotFound, http.StatusNotFound, "food_not_found"},
+	{repository.ErrFoodExists, http.StatusConflict, "food_exists"},
+	{repository.ErrOrderNotFound, http.StatusNotFound, "order_not_found"},
+	{repository.ErrAddressNotFound, http.StatusNotFound, "address_not_found"},
+	{repository.ErrAddressRequired, http.StatusBadRequest, "address_required"},
+	{repository.ErrDefaultAddressKept, http.StatusConflict, "default_address_kept"},
+	{repository.ErrAddressOutOfRange, http.StatusUnprocessableEntity, "address_out_of_range"},
+	{repository.ErrOutsideDeliveryArea, http.StatusUnprocessableEntity, "outside_delivery_area"},
+	{repository.ErrBelowZoneMinimum, http.StatusUnprocessableEntity, "below_zone_minimum"},
+	{repository.ErrDeliveryZoneNotFound, http.StatusNotFound, "delivery_zone_not_found"},
+	{repository.ErrInvalidFulfillment, http.StatusBadRequest, "invalid_fulfillment"},
+	{repository.ErrOrderForbidden, http.StatusForbidden, "order_forbidden"},
+	{repository.ErrOrderNotCancelable, http.StatusConflict, "order_not_cancelable"},
+	{repository.ErrCancelWindowExpired, http.StatusUnprocessableEntity, "cancel_window_expired"},
+	{repository.ErrIllegalTransition, http.StatusConflict, "illegal_transition"},
+	{repository.ErrWrongFulfillment, http.StatusConflict, "wrong_fulfillment"},
+	{repository.ErrMixedCurrencies, http.StatusConflict, "mixed_currencies"},
+	{repository.ErrCartEmpty, http.StatusBadRequest, "cart_empty"},
+	{repository.ErrCartItemLimit, http.StatusUnprocessableEntity, "cart_item_limit"},
+	{repository.ErrCartFull, http.StatusUnprocessableEntity, "cart_full"},
+	{repository.ErrPromotionNotFound, http.StatusNotFound, "promotion_not_found"},
+	{repository.ErrPromotionExists, http.StatusConflict, "promotion_exists"},
+	{repository.ErrPromoCodeInvalid, http.StatusBadRequest, "promo_code_invalid"},
+	{repository.ErrPromoNotActive, http.StatusBadRequest, "promo_not_active"},
+	{repository.ErrPromoUsedUp, http.StatusConflict, "promo_used_up"},
+	{repository.ErrPromoMinOrder, http.StatusBadRequest, "promo_min_order"},
+	{repository.ErrPromoNotApplicable, http.StatusBadRequest, "promo_not_applicable"},
+	{repository.ErrCartItemNotFound, http.StatusNotFound, "cart_item_not_found"},
+	{repository.ErrWebhookNotFound, http.StatusNotFound, "webhook_not_found"},
+	{repository.ErrWebhookDeliveryNotFound, http.StatusNotFound, "webhook_delivery_not_found"},
+	{repository.ErrRefundTooLarge, http.StatusBadRequest, "refund_too_large"},
+	{repository.ErrPaymentNotFound, http.StatusNotFound, "payment_not_found"},
+	{payment.ErrUnknownMethod, http.StatusNotFound, "payment_method_unavailable"},
+	{payment.ErrInvalidSignature, http.StatusUnauthorized, "invalid_signature"},
+	{payment.ErrInvalidWebhook, http.StatusBadRequest, "invalid_webhook"},
+	{payment.ErrWebhooksUnsupported, http.StatusNotFound, "webhooks_unsupported"},
+	{repository.ErrUserNotFound, http.StatusNotFound, "user_not_found"},
+	{repository.ErrInvalidCredentials, http.StatusUnauthorized, "invalid_credentials"},
+	{repository.ErrInvalidRefreshToken, http.StatusUnauthorized, "invalid_refresh_token"},
+	{repository.ErrRefreshTokenReused, http.StatusUnauthorized, "refresh_token_reused"},
+	{repository.ErrCodeInvalid, http.StatusBadRequest, "invalid_code"},
+	{repository.ErrCodeExpired, http.StatusBadRequest, "code_expired"},
+	{repository.ErrCodeTooManyAttempts, http.StatusTooManyRequests, "too_many_attempts"},
+}
+
+// respondError writes the error envelope for err. Errors that are not known
+// to the API are logged and reported as a generic internal error, so driver
+// messages never reach clients.
+func respondError(c *gin.Context, err error) {
+	for _, e := range apiErrors {
+		if errors.Is(err, e.err) {
+			response.Abort(c, e.status, e.code, err.Error())
+			return
+		}
+	}
+	log.Printf("%s %s: %v", c.Request.Method, c.FullPath(), err)
+	response.Abort(c, http.StatusInternalServerError, "internal_error", "Internal server error")
+}
+
+func init() {
+	// Report validation errors with JSON field names instead of Go names
+	if v, ok := binding.Validator.Engine().(*validator.Validate); ok {
+		v.RegisterTagNameFunc(func(f reflect.StructField) string {
+			name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+			if name == "-" {
+				return ""
+			}
+			if name == "" {
+				return f.Name
+			}
+			return name
+		})
+		// Order limits come from the repository, which enforces them on
+		// carts and merged lines too
+		v.RegisterAlias("item_count", fmt.Sprintf("gt=0,max=%d", repository.MaxItemCount))
+		v.RegisterAlias("order_lines", fmt.Sprintf("min=1,max=%d", repository.MaxOrderLines))
+	}
+}
+
+// arrayIndex matches the ".0" style indexes used by encoding/json field paths
+var arrayIndex = regexp.MustCompile(`\.(\d+)`)
+
+// bindJSON binds and validates the request body. On failure it writes the
+// error response and returns false.
+func bindJSON(c *gin.Context, obj any) bool {
+	err := c.ShouldBindJSON(obj)
+	if err == nil {
+		return true
+	}
+	var validationErrs validator.ValidationErrors
+	var typeErr *json.UnmarshalTypeError
+	switch {
+	case errors.As(err, &validationErrs):
+		details := make([]response.FieldError, 0, len(validationErrs))
+		for _, fe := range validationErrs {
+			details = append(details, response.FieldError{Field: fieldPath(fe), Message: validationMessage(fe)})
+		}
+		response.Abort(c, http.StatusBadRequest, "validation_failed", "Request body is invalid", details...)
+	case errors.As(err, &typeErr):
+		field := arrayIndex.ReplaceAllString(typeErr.Field, "[$1]")
+		response.Abort(c, http.StatusBadRequest, "validation_failed", "Request body is invalid",
+			response.FieldError{Field: field, Message: "must be " + typeName(typeErr.Type)})
+	default:
+		response.Abort(c, http.StatusBadRequest, "invalid_body", "Request body must be valid JSON")
+	}
+	return false
+}
+
+// fieldPath drops the request struct name from the validator namespace,
+// e.g. "CreateOrderInput.items[0].count" becomes "items[0].count"
+func fieldPath(fe validator.FieldError) string {
+	_, path, ok := strings.Cut(fe.Namespace(), ".")
+	if !ok {
+		return fe.Field()
+	}
+	return path
+}
+
+func validationMessage(fe validator.FieldError) string {
+	unit := ""
+	switch fe.Kind() {
+	case reflect.Slice, reflect.Array, reflect.Map:
+		unit = " item(s)"
+	case reflect.String:
+		unit = " character(s)"
+	}
+	switch fe.ActualTag() {
+	case "required":
+		return "is required"
+	case "min":
+		if unit != "" {
+			return "must contain at least " + fe.Param() + unit
+		}
+		return "must be at least " + fe.Param()
+	case "max":
+		if unit != "" {
+			return "must contain at most " + fe.Param() + unit
+		}
+		return "must be at most " + fe.Param()
+	case "len":
+		return "must be exactly " + fe.Param() + unit + " long"
+	case "gt":
+		return "must be greater than " + fe.Param()
+	case "gte":
+		return "must be at least " + fe.Param()
+	case "oneof":
+		return "must be one of: " + strings.ReplaceAll(fe.Param(), " ", ", ")
+	case "url":
+		return "must be a valid URL"
+	case "http_url":
+		return "must be a valid http or https URL"
+	case "email":
+		return "must be a valid email address"
+	case "numeric":
+		return "must contain only digits"
+	case "iso4217":
+		return "must be an ISO 4217 currency code"
+	case "latitude":
+		return "must be a latitude between -90 and 90"
+	case "longitude":
+		return "must be a longitude between -180 and 180"
+	}
+	return "is invalid"
+}
+
+// typeName describes a Go type in JSON terms
+func typeName(t reflect.Type) string {
+	switch t.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return "an integer"
+	case reflect.Float32, reflect.Float64:
+		return "a number"
+	case reflect.String:
+		return "a string"
+	case reflect.Bool:
+		return "a boolean"
+	case reflect.Slice, reflect.Array:
+		return "an array"
+	}
+	return "an object"
+}
+
+// pathID parses a numeric path parameter. On failure it writes the error
+// response and returns false.
+func pathID(c *gin.Context, name string) (int, bool) {
+	id, err := strconv.Atoi(c.Param(name))
+	if err != nil || id <= 0 {
+		response.Abort(c, http.StatusBadRequest, "invalid_id", "Invalid "+name,
+			response.FieldError{Field: name, Message: "must be a positive integer"})
+		return 0, false
+	}
+	return id, true
+}
@@ -0,0 +1,52 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/Anwarjondev/fast-food/internal/repository"
+	"github.com/Anwarjondev/fast-food/internal/response"
+	"github.com/gin-gonic/gin"
+)
+
+func TestBindJSONOrderLimits(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	lines := make([]string, repository.MaxOrderLines+1)
+	for i := range lines {
+		lines[i] = fmt.Sprintf(`{"food_id":%d,"count":1}`, i+1)
+	}
+	tests := []struct {
+		name    string
+		body    string
+		field   string
+		message string
+	}{
+		{"count over the limit", fmt.Sprintf(`{"items":[{"food_id":1,"count":%d}]}`, repository.MaxItemCount+1),
+			"items[0].count", fmt.Sprintf("must be at most %d", repository.MaxItemCount)},
+		{"count not positive", `{"items":[{"food_id":1,"count":0}]}`, "items[0].count", "must be greater than 0"},
+		{"too many lines", `{"items":[` + strings.Join(lines, ",") + `]}`,
+			"items", fmt.Sprintf("must contain at most %d item(s)", repository.MaxOrderLines)},
+		{"no lines", `{"items":[]}`, "items", "must contain at least 1 item(s)"},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request = httptest.NewRequest(http.MethodPost, "/orders", strings.NewReader(tt.body))
+		var input CreateOrderInput
+		if bindJSON(c, &input) {
+			t.Errorf("%s: bindJSON accepted the body", tt.name)
+			continue
+		}
+		var body response.Error
+		if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if len(body.Error.Details) != 1 || body.Error.Details[0].Field != tt.field || body.Error.Details[0].Message != tt.message {
+			t.Errorf("%s: details = %+v, want %s %q", tt.name, body.Error.Details, tt.field, tt.message)
+		}
+	}
+}
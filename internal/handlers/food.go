@@ -2,12 +2,44 @@ package handlers
 
 import (
 	"net/http"
-	"strconv"
 
+	"github.com/Anwarjondev/fast-food/internal/money"
 	"github.com/Anwarjondev/fast-food/internal/repository"
 	"github.com/gin-gonic/gin"
 )
 
+// FoodRequest represents the request body for creating or updating a food.
+// price is in minor units of currency (e.g. 1250 is 12.50 USD); currency
+// defaults to the configured one.
+type FoodRequest struct {
+	Name       string `json:"name" binding:"required,max=100"`
+	Price      int64  `json:"price" binding:"required,gt=0"`
+	Currency   string `json:"currency" binding:"omitempty,iso4217"`
+	CategoryID int    `json:"category_id" binding:"required,gt=0"`
+	ImageURL   string `json:"img_url" binding:"omitempty,url"`
+	CountFood  int    `json:"count_food" binding:"min=0"`
+}
+
+func (r FoodRequest) food(id int) repository.Food {
+	return repository.Food{
+		ID:         id,
+		Name:       r.Name,
+		Price:      money.New(r.Price, currencyOrDefault(r.Currency)),
+		CategoryID: r.CategoryID,
+		ImageURL:   r.ImageURL,
+		CountFood:  r.CountFood,
+	}
+}
+
+// currencyOrDefault returns currency, or the configured currency when the
+// request did not name one
+func currencyOrDefault(currency string) string {
+	if currency == "" {
+		return appConfig.Currency
+	}
+	return currency
+}
+
 // GetFoodsByCategory godoc
 // @Summary Get foods by category
 // @Description Get list of foods in a category
@@ -18,17 +50,114 @@ import (
 // @Success 200 {object} []repository.Food
 // @Router /categories/{id}/foods [get]
 func GetFoodsByCategory(c *gin.Context) {
-	categoryIDStr := c.Param("id")
-	categoryID, err := strconv.Atoi(categoryIDStr)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid category ID"})
+	categoryID, ok := pathID(c, "id")
+	if !ok {
 		return
 	}
 	foods, err := repository.GetFoodsByCategory(categoryID)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		respondError(c, err)
 		return
 	}
 
 	c.JSON(http.StatusOK, foods)
 }
+
+// CreateFood godoc
+// @Summary Create food
+// @Description Add a new food to a category (admin only)
+// @Tags admin
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param request body FoodRequest true "Food"
+// @Success 201 {object} repository.Food
+// @Router /admin/foods [post]
+func CreateFood(c *gin.Context) {
+	var req FoodRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+	food, err := repository.CreateFood(req.food(0))
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, food)
+}
+
+// UpdateFood godoc
+// @Summary Update food
+// @Description Update name, price, image, stock or category of a food (admin only)
+// @Tags admin
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param id path int true "Food ID"
+// @Param request body FoodRequest true "Food"
+// @Success 200 {object} repository.Food
+// @Router /admin/foods/{id} [put]
+func UpdateFood(c *gin.Context) {
+	id, ok := pathID(c, "id")
+	if !ok {
+		return
+	}
+	var req FoodRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+	food, err := repository.UpdateFood(req.food(id))
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, food)
+}
+
+// DeleteFood godoc
+// @Summary Delete food
+// @Description Soft-delete a food so it no longer appears on the menu (admin only)
+// @Tags admin
+// @Security BearerAuth
+// @Produce json
+// @Param id path int true "Food ID"
+// @Success 200 {object} map[string]interface{}
+// @Router /admin/foods/{id} [delete]
+func DeleteFood(c *gin.Context) {
+	id, ok := pathID(c, "id")
+	if !ok {
+		return
+	}
+	if err := repository.DeleteFood(id); err != nil {
+		respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Food deleted"})
+}
+
+// ReorderFoods godoc
+// @Summary Reorder foods
+// @Description Set the display order of foods in a category (admin only)
+// @Tags admin
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param id path int true "Category ID"
+// @Param request body ReorderRequest true "Food IDs in display order"
+// @Success 200 {object} map[string]interface{}
+// @Router /admin/categories/{id}/foods/order [put]
+func ReorderFoods(c *gin.Context) {
+	categoryID, ok := pathID(c, "id")
+	if !ok {
+		return
+	}
+	var req ReorderRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+	if err := repository.ReorderFoods(categoryID, req.IDs); err != nil {
+		respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Foods reordered"})
+}
@@ -0,0 +1,134 @@
+package handlers
+
+import (
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/Anwarjondev/fast-food/internal/repository"
+	"github.com/Anwarjondev/fast-food/internal/response"
+	"github.com/gin-gonic/gin"
+)
+
+// RejectOrderRequest represents the request body for rejecting an order.
+// refund_amount is in minor units of the order currency; without it the
+// whole payment is refunded.
+type RejectOrderRequest struct {
+	Reason       string `json:"reason" binding:"required,max=500"`
+	RefundAmount *int64 `json:"refund_amount" binding:"omitempty,gte=0"`
+}
+
+// GetKitchenQueue godoc
+// @Summary Get kitchen queue
+// @Description List orders the kitchen still has to work on, oldest first, with their lines (staff only)
+// @Tags kitchen
+// @Security BearerAuth
+// @Produce json
+// @Param status query string false "Only orders in this status (placed, accepted, preparing, ready)"
+// @Success 200 {object} []repository.OrderWithLines
+// @Failure 400 {object} response.Error
+// @Router /kitchen/orders [get]
+func GetKitchenQueue(c *gin.Context) {
+	statuses := repository.KitchenStatuses
+	if status := c.Query("status"); status != "" {
+		if !slices.Contains(repository.KitchenStatuses, status) {
+			response.Abort(c, http.StatusBadRequest, "invalid_status", "Invalid status",
+				response.FieldError{Field: "status", Message: "must be one of: " + strings.Join(repository.KitchenStatuses, ", ")})
+			return
+		}
+		statuses = []string{status}
+	}
+	queue, err := repository.GetKitchenQueue(statuses)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, queue)
+}
+
+// AdvanceOrder godoc
+// @Summary Advance order
+// @Description Move an order to the next kitchen status: accept, prepare or ready. Fails with 409 when the order is not in a status that allows it (staff only).
+// @Tags kitchen
+// @Security BearerAuth
+// @Produce json
+// @Param order_id path int true "Order ID"
+// @Success 200 {object} repository.Order
+// @Failure 404 {object} response.Error
+// @Failure 409 {object} response.Error
+// @Router /kitchen/orders/{order_id}/accept [post]
+// @Router /kitchen/orders/{order_id}/prepare [post]
+// @Router /kitchen/orders/{order_id}/ready [post]
+func AdvanceOrder(status string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, ok := pathID(c, "order_id")
+		if !ok {
+			return
+		}
+		order, err := repository.TransitionOrder(id, status, c.GetInt("user_id"), "")
+		if err != nil {
+			respondError(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, order)
+	}
+}
+
+// HandOverOrder godoc
+// @Summary Hand over order
+// @Description Move an order of one fulfillment on its way to the customer: the kitchen completes ready pickup orders, and couriers pick up ready delivery orders and deliver them. Fails with 409 when the order is not in a status that allows it or is fulfilled the other way (staff only).
+// @Tags kitchen
+// @Security BearerAuth
+// @Produce json
+// @Param order_id path int true "Order ID"
+// @Success 200 {object} repository.Order
+// @Failure 404 {object} response.Error
+// @Failure 409 {object} response.Error
+// @Router /kitchen/orders/{order_id}/complete [post]
+// @Router /courier/orders/{order_id}/pickup [post]
+// @Router /courier/orders/{order_id}/deliver [post]
+func HandOverOrder(fulfillment, status string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, ok := pathID(c, "order_id")
+		if !ok {
+			return
+		}
+		order, err := repository.TransitionFulfilledOrder(id, fulfillment, status, c.GetInt("user_id"))
+		if err != nil {
+			respondError(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, order)
+	}
+}
+
+// RejectOrder godoc
+// @Summary Reject order
+// @Description Reject an order that has not been made yet and put its foods back into stock (staff only). A card payment is refunded in full, or by refund_amount; the refund is sent to the provider in the background and shows on the order.
+// @Tags kitchen
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param order_id path int true "Order ID"
+// @Param request body RejectOrderRequest true "Why the order is rejected"
+// @Success 200 {object} repository.Order
+// @Failure 400 {object} response.Error
+// @Failure 404 {object} response.Error
+// @Failure 409 {object} response.Error
+// @Router /kitchen/orders/{order_id}/reject [post]
+func RejectOrder(c *gin.Context) {
+	id, ok := pathID(c, "order_id")
+	if !ok {
+		return
+	}
+	var req RejectOrderRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+	order, err := repository.RejectOrder(id, c.GetInt("user_id"), req.Reason, req.RefundAmount)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, order)
+}
@@ -1,47 +1,184 @@
 package handlers
 
 import (
+	"errors"
+	"fmt"
 	"net/http"
-	"strconv"
 
+	"github.com/Anwarjondev/fast-food/internal/geo"
 	"github.com/Anwarjondev/fast-food/internal/repository"
+	"github.com/Anwarjondev/fast-food/internal/response"
 	"github.com/gin-gonic/gin"
 )
 
+// OrderItemInput is one line of an order. The count limit is
+// repository.MaxItemCount.
+type OrderItemInput struct {
+	FoodID int `json:"food_id" binding:"gt=0"`
+	Count  int `json:"count" binding:"item_count"`
+}
+
+// CreateOrderInput is a new order. payment_method defaults to the first
+// configured method; payment_token is what the card provider gave the client.
+// fulfillment defaults to pickup; delivery orders go to address_id, or to the
+// default address when it is left out. The line limit is
+// repository.MaxOrderLines.
 type CreateOrderInput struct {
-	Items []repository.OrderDetail `json:"items"`
+	Items         []OrderItemInput `json:"items" binding:"required,order_lines,dive"`
+	PromoCode     string           `json:"promo_code" binding:"max=50"`
+	PaymentMethod string           `json:"payment_method" binding:"max=30"`
+	PaymentToken  string           `json:"payment_token" binding:"max=200"`
+	Fulfillment   string           `json:"fulfillment" binding:"omitempty,oneof=delivery pickup"`
+	AddressID     int              `json:"address_id" binding:"gte=0"`
+}
+
+// mergeItems combines lines for the same food, keeping the order in which
+// foods first appear. Merged lines are held to the same limit as single
+// ones; when they exceed it, mergeItems writes the validation error against
+// the line that went over and returns false.
+func mergeItems(c *gin.Context, items []OrderItemInput) ([]repository.OrderDetail, bool) {
+	index := map[int]int{}
+	var merged []repository.OrderDetail
+	for n, item := range items {
+		i, ok := index[item.FoodID]
+		if !ok {
+			index[item.FoodID] = len(merged)
+			merged = append(merged, repository.OrderDetail{FoodID: item.FoodID, Count: item.Count})
+			continue
+		}
+		merged[i].Count += item.Count
+		if merged[i].Count > repository.MaxItemCount {
+			response.Abort(c, http.StatusBadRequest, "validation_failed", "Request body is invalid",
+				response.FieldError{
+					Field:   fmt.Sprintf("items[%d].count", n),
+					Message: fmt.Sprintf("must be at most %d together with earlier lines for the same food", repository.MaxItemCount),
+				})
+			return nil, false
+		}
+	}
+	return merged, true
+}
+
+// orderCharges returns the configured taxes and fees for new orders
+func orderCharges() repository.Charges {
+	return repository.Charges{
+		TaxInclusive:  appConfig.TaxInclusive,
+		TaxRate:       appConfig.TaxRate,
+		ServiceCharge: appConfig.ServiceCharge,
+		PackagingFee:  appConfig.PackagingFee,
+		Delivery: repository.DeliveryRates{
+			Kitchen:       geo.Point{Lat: appConfig.KitchenLatitude, Lng: appConfig.KitchenLongitude},
+			BaseFee:       appConfig.DeliveryBaseFee,
+			FeePerKm:      appConfig.DeliveryFeePerKm,
+			MaxDistanceKm: appConfig.DeliveryMaxDistanceKm,
+		},
+	}
+}
+
+// kitchenTimes returns the configured times for estimating when orders are
+// done
+func kitchenTimes() repository.KitchenTimes {
+	return repository.KitchenTimes{
+		PrepTime:   appConfig.PrepTime,
+		QueueTime:  appConfig.QueueTime,
+		TravelTime: appConfig.DeliveryTravelTime,
+	}
+}
+
+// itemField returns the field path of the first request line for foodID
+func itemField(items []OrderItemInput, foodID int, field string) string {
+	for i, item := range items {
+		if item.FoodID == foodID {
+			return fmt.Sprintf("items[%d].%s", i, field)
+		}
+	}
+	return "items"
 }
 
 // CreateOrder godoc
 // @Summary Create new order
-// @Description Create a new food order
+// @Description Create a new food order and authorize its payment. Lines for the same food are merged. Orders are picked up unless fulfillment is delivery, in which case they go to address_id or the default address, which must lie in a delivery zone once zones exist, and a delivery fee is added. The response includes estimated_at, when the order should arrive or be ready, based on the kitchen queue and the zone's travel time. The order is paid once a card payment is authorized, placed when it is paid on delivery, and stays payment_pending while the provider decides. Fails with 409 and the affected items when stock is insufficient, with 400 when the promo code cannot be used, and with 402 or 502 when the payment is declined or fails, which cancels the order.
 // @Tags orders
 // @Security BearerAuth
 // @Accept json
 // @Produce json
 // @Param order body CreateOrderInput true "Order details"
 // @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} response.Error
+// @Failure 402 {object} response.Error
+// @Failure 409 {object} response.Error
+// @Failure 502 {object} response.Error
 // @Router /orders [post]
 func CreateOrder(c *gin.Context) {
 	userID := c.GetInt("user_id")
 	var input CreateOrderInput
+	if !bindJSON(c, &input) {
+		return
+	}
+	items, ok := mergeItems(c, input.Items)
+	if !ok {
+		return
+	}
+	provider, ok := paymentProvider(c, input.PaymentMethod)
+	if !ok {
+		return
+	}
 
-	err := c.BindJSON(&input)
+	order, err := repository.CreateOrder(userID, repository.NewOrder{
+		Items:         items,
+		PromoCode:     input.PromoCode,
+		PaymentMethod: provider.Name(),
+		Fulfillment:   input.Fulfillment,
+		AddressID:     input.AddressID,
+	}, orderCharges(), kitchenTimes())
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		respondOrderError(c, err, func(foodID int, field string) string {
+			return itemField(input.Items, foodID, field)
+		})
 		return
 	}
-	orderID, err := repository.CreateOrder(userID, input.Items)
+	order, paymentStatus, err := authorizeOrder(order, provider, input.PaymentToken)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		respondError(c, err)
+		return
+	}
+	respondPlacedOrder(c, order, paymentStatus)
+}
+
+// respondOrderError writes the error response for a failed order placement,
+// listing unknown foods and stock shortages under the request fields that
+// field names for them
+func respondOrderError(c *gin.Context, err error, field func(foodID int, name string) string) {
+	var unknownErr *repository.UnknownFoodError
+	if errors.As(err, &unknownErr) {
+		details := make([]response.FieldError, 0, len(unknownErr.FoodIDs))
+		for _, id := range unknownErr.FoodIDs {
+			details = append(details, response.FieldError{
+				Field:   field(id, "food_id"),
+				Message: fmt.Sprintf("food %d does not exist", id),
+			})
+		}
+		response.Abort(c, http.StatusBadRequest, "unknown_food", "Some foods do not exist", details...)
+		return
+	}
+	var stockErr *repository.InsufficientStockError
+	if errors.As(err, &stockErr) {
+		details := make([]response.FieldError, 0, len(stockErr.Items))
+		for _, item := range stockErr.Items {
+			details = append(details, response.FieldError{
+				Field:   field(item.FoodID, "count"),
+				Message: fmt.Sprintf("only %d left of food %d, requested %d", item.Available, item.FoodID, item.Requested),
+			})
+		}
+		response.Abort(c, http.StatusConflict, "insufficient_stock", "Some foods are out of stock", details...)
 		return
 	}
-	c.JSON(http.StatusOK, gin.H{"order_id": orderID})
+	respondError(c, err)
 }
 
 // GetOrderByStatus godoc
 // @Summary Get orders by status
-// @Description Get list of orders by status (active, completed, all)
+// @Description Get list of orders by status (active: not finished yet, completed: delivered, all)
 // @Tags orders
 // @Security BearerAuth
 // @Produce json
@@ -55,34 +192,117 @@ func GetOrderByStatus(status string) gin.HandlerFunc {
 		userID := c.GetInt("user_id")
 		orders, err := repository.GetAllOrderByStatus(userID, status)
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			respondError(c, err)
 			return
 		}
 		c.JSON(http.StatusOK, orders)
 	}
 }
 
+// isStaff reports whether the user has any role besides customer
+func isStaff(c *gin.Context) bool {
+	for _, role := range c.GetStringSlice("roles") {
+		if role != repository.RoleCustomer {
+			return true
+		}
+	}
+	return false
+}
+
+// findOrder loads the order named by the order_id path parameter. Customers
+// only see their own orders; other orders are reported as not found.
+func findOrder(c *gin.Context) (repository.OrderWithLines, bool) {
+	id, ok := pathID(c, "order_id")
+	if !ok {
+		return repository.OrderWithLines{}, false
+	}
+	order, err := repository.GetOrder(id)
+	if err == nil && order.UserID != c.GetInt("user_id") && !isStaff(c) {
+		err = repository.ErrOrderNotFound
+	}
+	if err != nil {
+		respondError(c, err)
+		return order, false
+	}
+	return order, true
+}
+
+// GetOrder godoc
+// @Summary Get order
+// @Description Get an order with its lines. Customers can only see their own orders; staff can see any.
+// @Tags orders
+// @Security BearerAuth
+// @Produce json
+// @Param order_id path int true "Order ID"
+// @Success 200 {object} repository.OrderWithLines
+// @Failure 404 {object} response.Error
+// @Router /orders/{order_id} [get]
+func GetOrder(c *gin.Context) {
+	order, ok := findOrder(c)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, order)
+}
+
+// GetOrderHistory godoc
+// @Summary Get order timeline
+// @Description Get every status change of an order, oldest first, with who made it and why
+// @Tags orders
+// @Security BearerAuth
+// @Produce json
+// @Param order_id path int true "Order ID"
+// @Success 200 {object} []repository.OrderStatusChange
+// @Failure 404 {object} response.Error
+// @Router /orders/{order_id}/history [get]
+func GetOrderHistory(c *gin.Context) {
+	order, ok := findOrder(c)
+	if !ok {
+		return
+	}
+	history, err := repository.GetOrderHistory(order.ID)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, history)
+}
+
+// CancelOrderRequest represents the optional request body for canceling an
+// order
+type CancelOrderRequest struct {
+	Reason string `json:"reason" binding:"max=500"`
+}
+
 // CancelOrder godoc
 // @Summary Cancel order
-// @Description Cancel an existing order
+// @Description Cancel your own order, with an optional reason. Whether and for how long after ordering an order can be canceled depends on its status (CANCEL_WINDOWS). A card payment is refunded in full; the refund is sent to the provider in the background and shows on the order.
 // @Tags orders
 // @Security BearerAuth
+// @Accept json
 // @Produce json
 // @Param order_id path int true "Order ID"
+// @Param request body CancelOrderRequest false "Why the order is canceled"
 // @Success 200 {object} map[string]interface{}
+// @Failure 403 {object} response.Error
+// @Failure 404 {object} response.Error
+// @Failure 409 {object} response.Error
+// @Failure 422 {object} response.Error
 // @Router /orders/{order_id} [put]
 func CancelOrder(c *gin.Context) {
 	userID := c.GetInt("user_id")
-	orderID := c.Param("order_id")
-	id, err := strconv.Atoi(orderID)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid order ID"})
+	id, ok := pathID(c, "order_id")
+	if !ok {
+		return
+	}
+	var req CancelOrderRequest
+	if c.Request.ContentLength != 0 && !bindJSON(c, &req) {
 		return
 	}
-	err = repository.CancelOrder(userID, id)
+	order, err := repository.CancelOrder(userID, id, req.Reason, appConfig.CancelWindows)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		respondError(c, err)
 		return
 	}
-	c.JSON(http.StatusOK, gin.H{"message": "Order canceled"})
+	c.JSON(http.StatusOK, gin.H{"message": "Order canceled", "order_id": order.ID, "status": order.Status})
 }
@@ -0,0 +1,138 @@
+package handlers
+
+import (
+	"context"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/Anwarjondev/fast-food/internal/payment"
+	"github.com/Anwarjondev/fast-food/internal/repository"
+	"github.com/Anwarjondev/fast-food/internal/response"
+	"github.com/gin-gonic/gin"
+)
+
+// paymentTimeout bounds a call to a payment provider
+const paymentTimeout = 30 * time.Second
+
+// paymentStatuses maps what a provider decided to the status stored for the
+// payment
+var paymentStatuses = map[string]string{
+	payment.StatusAuthorized: repository.PaymentAuthorized,
+	payment.StatusDeferred:   repository.PaymentDeferred,
+	payment.StatusPending:    repository.PaymentPending,
+	payment.StatusDeclined:   repository.PaymentDeclined,
+}
+
+// paymentProvider returns the provider for a payment method chosen in a
+// request, defaulting to the first configured method. On failure it writes
+// the error response and returns false.
+func paymentProvider(c *gin.Context, method string) (payment.Provider, bool) {
+	if method == "" {
+		method = appConfig.PaymentMethods[0]
+	}
+	provider, err := payment.Get(method)
+	if err != nil {
+		response.Abort(c, http.StatusBadRequest, "payment_method_unavailable", "Payment method is not available",
+			response.FieldError{Field: "payment_method", Message: "must be one of: " + strings.Join(payment.Methods(), ", ")})
+		return nil, false
+	}
+	return provider, true
+}
+
+// authorizeOrder asks the provider to authorize the payment of a new order
+// and records the outcome, which moves the order to paid or placed, keeps it
+// waiting for a webhook, or cancels it. It returns the order afterwards and
+// the stored payment status.
+func authorizeOrder(order repository.Order, provider payment.Provider, token string) (repository.Order, string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), paymentTimeout)
+	defer cancel()
+	result, err := provider.Authorize(ctx, payment.AuthorizeRequest{OrderID: order.ID, Amount: order.TotalPrice, Token: token})
+	status, ok := paymentStatuses[result.Status]
+	message := result.Message
+	if err != nil || !ok {
+		log.Printf("Authorizing payment of order %d with %s: %v (status %q)", order.ID, provider.Name(), err, result.Status)
+		status, message = repository.PaymentFailed, "payment provider error"
+	}
+	order, err = repository.ResolvePayment(order.ID, status, result.Reference, message)
+	return order, status, err
+}
+
+// respondPlacedOrder replies to a request that placed an order, after its
+// payment was authorized. Declined and failed payments have canceled the
+// order and are reported as errors.
+func respondPlacedOrder(c *gin.Context, order repository.Order, paymentStatus string) {
+	switch paymentStatus {
+	case repository.PaymentDeclined:
+		response.Abort(c, http.StatusPaymentRequired, "payment_declined", "Payment was declined, the order was canceled")
+	case repository.PaymentFailed:
+		response.Abort(c, http.StatusBadGateway, "payment_failed", "Payment could not be processed, the order was canceled")
+	default:
+		c.JSON(http.StatusOK, gin.H{
+			"order_id":       order.ID,
+			"status":         order.Status,
+			"payment_status": paymentStatus,
+			"total_price":    order.TotalPrice,
+			"fulfillment":    order.Fulfillment,
+			"delivery_fee":   order.DeliveryFee,
+			"estimated_at":   order.EstimatedAt,
+		})
+	}
+}
+
+// PaymentWebhook godoc
+// @Summary Payment provider webhook
+// @Description Receives payment updates from a payment provider, such as the outcome of a payment that was pending. The provider's signature is checked; no bearer token is needed.
+// @Tags payments
+// @Accept json
+// @Produce json
+// @Param method path string true "Payment method"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} response.Error
+// @Failure 401 {object} response.Error
+// @Failure 404 {object} response.Error
+// @Router /payments/{method}/webhook [post]
+func PaymentWebhook(c *gin.Context) {
+	provider, err := payment.Get(c.Param("method"))
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	body, err := io.ReadAll(io.LimitReader(c.Request.Body, 1<<20))
+	if err != nil {
+		response.Abort(c, http.StatusBadRequest, "invalid_body", "Request body could not be read")
+		return
+	}
+	e, err := provider.VerifyWebhook(c.Request.Header, body)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	status, ok := paymentStatuses[e.Status]
+	if !ok {
+		response.Abort(c, http.StatusBadRequest, "invalid_payment_status", "Unknown payment status",
+			response.FieldError{Field: "status", Message: "must be one of: authorized, deferred, pending, declined"})
+		return
+	}
+	p, err := repository.GetPaymentByReference(provider.Name(), e.Reference)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	order, err := repository.ResolvePayment(p.OrderID, status, "", e.Message)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	// The order was estimated when it was placed; the kitchen only starts
+	// on it now
+	if p.Status == repository.PaymentPending && (order.Status == repository.StatusPaid || order.Status == repository.StatusPlaced) {
+		if _, err := repository.EstimateOrder(order.ID, kitchenTimes()); err != nil {
+			respondError(c, err)
+			return
+		}
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Payment updated"})
+}
@@ -0,0 +1,211 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/Anwarjondev/fast-food/internal/money"
+	"github.com/Anwarjondev/fast-food/internal/repository"
+	"github.com/Anwarjondev/fast-food/internal/response"
+	"github.com/gin-gonic/gin"
+)
+
+// PromotionRequest represents the request body for creating or updating a
+// promotion. percent is the percent off for percentage codes and amount the
+// amount off for fixed codes, in minor units of currency like min_order;
+// buy_count and get_count are used by buy_x_get_y codes. currency defaults to
+// the configured one.
+type PromotionRequest struct {
+	Code           string     `json:"code" binding:"required,max=50"`
+	Kind           string     `json:"kind" binding:"required,oneof=percentage fixed buy_x_get_y"`
+	Percent        float64    `json:"percent" binding:"gte=0"`
+	Amount         int64      `json:"amount" binding:"gte=0"`
+	Currency       string     `json:"currency" binding:"omitempty,iso4217"`
+	CategoryID     *int       `json:"category_id" binding:"omitempty,gt=0"`
+	FoodID         *int       `json:"food_id" binding:"omitempty,gt=0"`
+	BuyCount       int        `json:"buy_count" binding:"gte=0"`
+	GetCount       int        `json:"get_count" binding:"gte=0"`
+	MinOrder       int64      `json:"min_order" binding:"gte=0"`
+	MaxUses        *int       `json:"max_uses" binding:"omitempty,gt=0"`
+	MaxUsesPerUser *int       `json:"max_uses_per_user" binding:"omitempty,gt=0"`
+	StartsAt       *time.Time `json:"starts_at"`
+	EndsAt         *time.Time `json:"ends_at"`
+	IsActive       *bool      `json:"is_active"`
+}
+
+// problems reports the rules that depend on the kind of promotion
+func (r PromotionRequest) problems() []response.FieldError {
+	var problems []response.FieldError
+	switch r.Kind {
+	case repository.PromoPercentage:
+		if r.Percent <= 0 || r.Percent > 100 {
+			problems = append(problems, response.FieldError{Field: "percent", Message: "must be a percentage between 0 and 100"})
+		}
+	case repository.PromoFixed:
+		if r.Amount <= 0 {
+			problems = append(problems, response.FieldError{Field: "amount", Message: "must be greater than 0"})
+		}
+	case repository.PromoBuyXGetY:
+		if r.BuyCount < 1 {
+			problems = append(problems, response.FieldError{Field: "buy_count", Message: "must be at least 1"})
+		}
+		if r.GetCount < 1 {
+			problems = append(problems, response.FieldError{Field: "get_count", Message: "must be at least 1"})
+		}
+	}
+	if r.StartsAt != nil && r.EndsAt != nil && !r.EndsAt.After(*r.StartsAt) {
+		problems = append(problems, response.FieldError{Field: "ends_at", Message: "must be after starts_at"})
+	}
+	return problems
+}
+
+func (r PromotionRequest) promotion(id int) repository.Promotion {
+	currency := currencyOrDefault(r.Currency)
+	return repository.Promotion{
+		ID:             id,
+		Code:           r.Code,
+		Kind:           r.Kind,
+		Percent:        r.Percent,
+		Amount:         money.New(r.Amount, currency),
+		CategoryID:     r.CategoryID,
+		FoodID:         r.FoodID,
+		BuyCount:       r.BuyCount,
+		GetCount:       r.GetCount,
+		MinOrder:       money.New(r.MinOrder, currency),
+		MaxUses:        r.MaxUses,
+		MaxUsesPerUser: r.MaxUsesPerUser,
+		StartsAt:       r.StartsAt,
+		EndsAt:         r.EndsAt,
+		IsActive:       r.IsActive == nil || *r.IsActive,
+	}
+}
+
+// bindPromotion binds and validates a promotion request. On failure it
+// writes the error response and returns false.
+func bindPromotion(c *gin.Context, req *PromotionRequest) bool {
+	if !bindJSON(c, req) {
+		return false
+	}
+	if problems := req.problems(); len(problems) > 0 {
+		response.Abort(c, http.StatusBadRequest, "validation_failed", "Request body is invalid", problems...)
+		return false
+	}
+	return true
+}
+
+// GetPromotions godoc
+// @Summary List promotions
+// @Description List promo codes (admin only)
+// @Tags admin
+// @Security BearerAuth
+// @Produce json
+// @Success 200 {object} []repository.Promotion
+// @Router /admin/promotions [get]
+func GetPromotions(c *gin.Context) {
+	promotions, err := repository.GetPromotions()
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, promotions)
+}
+
+// GetPromotion godoc
+// @Summary Get promotion
+// @Description Get a promo code (admin only)
+// @Tags admin
+// @Security BearerAuth
+// @Produce json
+// @Param id path int true "Promotion ID"
+// @Success 200 {object} repository.Promotion
+// @Failure 404 {object} response.Error
+// @Router /admin/promotions/{id} [get]
+func GetPromotion(c *gin.Context) {
+	id, ok := pathID(c, "id")
+	if !ok {
+		return
+	}
+	promotion, err := repository.GetPromotion(id)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, promotion)
+}
+
+// CreatePromotion godoc
+// @Summary Create promotion
+// @Description Create a promo code (admin only)
+// @Tags admin
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param request body PromotionRequest true "Promotion"
+// @Success 201 {object} repository.Promotion
+// @Failure 400 {object} response.Error
+// @Failure 409 {object} response.Error
+// @Router /admin/promotions [post]
+func CreatePromotion(c *gin.Context) {
+	var req PromotionRequest
+	if !bindPromotion(c, &req) {
+		return
+	}
+	promotion, err := repository.CreatePromotion(req.promotion(0))
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, promotion)
+}
+
+// UpdatePromotion godoc
+// @Summary Update promotion
+// @Description Update a promo code; orders that already used it keep their discount (admin only)
+// @Tags admin
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param id path int true "Promotion ID"
+// @Param request body PromotionRequest true "Promotion"
+// @Success 200 {object} repository.Promotion
+// @Failure 404 {object} response.Error
+// @Failure 409 {object} response.Error
+// @Router /admin/promotions/{id} [put]
+func UpdatePromotion(c *gin.Context) {
+	id, ok := pathID(c, "id")
+	if !ok {
+		return
+	}
+	var req PromotionRequest
+	if !bindPromotion(c, &req) {
+		return
+	}
+	promotion, err := repository.UpdatePromotion(req.promotion(id))
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, promotion)
+}
+
+// DeletePromotion godoc
+// @Summary Delete promotion
+// @Description Soft-delete a promo code so it can no longer be used (admin only)
+// @Tags admin
+// @Security BearerAuth
+// @Produce json
+// @Param id path int true "Promotion ID"
+// @Success 200 {object} map[string]interface{}
+// @Failure 404 {object} response.Error
+// @Router /admin/promotions/{id} [delete]
+func DeletePromotion(c *gin.Context) {
+	id, ok := pathID(c, "id")
+	if !ok {
+		return
+	}
+	if err := repository.DeletePromotion(id); err != nil {
+		respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Promotion deleted"})
+}
@@ -0,0 +1,95 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/Anwarjondev/fast-food/internal/repository"
+	"github.com/Anwarjondev/fast-food/internal/response"
+	"github.com/gin-gonic/gin"
+)
+
+// dateQuery parses a YYYY-MM-DD query parameter, or returns def when it is
+// missing. On failure it writes the error response and returns false.
+func dateQuery(c *gin.Context, name string, def time.Time) (time.Time, bool) {
+	value := c.Query(name)
+	if value == "" {
+		return def, true
+	}
+	date, err := time.Parse(time.DateOnly, value)
+	if err != nil {
+		response.Abort(c, http.StatusBadRequest, "invalid_date", "Invalid "+name,
+			response.FieldError{Field: name, Message: "must be a date such as 2024-01-31"})
+		return date, false
+	}
+	return date, true
+}
+
+// reportRange reads the from and to days of a report, by default the last 30
+// days, and returns them as the start of from and the end of to. On failure
+// it writes the error response and returns false.
+func reportRange(c *gin.Context) (time.Time, time.Time, bool) {
+	today := time.Now().Truncate(24 * time.Hour)
+	to, ok := dateQuery(c, "to", today)
+	if !ok {
+		return to, to, false
+	}
+	from, ok := dateQuery(c, "from", to.AddDate(0, 0, -29))
+	if !ok {
+		return from, to, false
+	}
+	if to.Before(from) {
+		response.Abort(c, http.StatusBadRequest, "invalid_date", "Invalid to",
+			response.FieldError{Field: "to", Message: "must not be before from"})
+		return from, to, false
+	}
+	return from, to.AddDate(0, 0, 1), true
+}
+
+// GetSalesReport godoc
+// @Summary Sales report
+// @Description Subtotal, discounts, service charge, packaging, tax and total of the orders placed per day, leaving out canceled, rejected and refunded orders (admin only). Defaults to the last 30 days.
+// @Tags admin
+// @Security BearerAuth
+// @Produce json
+// @Param from query string false "First day, YYYY-MM-DD"
+// @Param to query string false "Last day, YYYY-MM-DD"
+// @Success 200 {object} []repository.SalesDay
+// @Failure 400 {object} response.Error
+// @Router /admin/reports/sales [get]
+func GetSalesReport(c *gin.Context) {
+	from, to, ok := reportRange(c)
+	if !ok {
+		return
+	}
+	days, err := repository.GetSalesReport(from, to)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, days)
+}
+
+// GetRefundReport godoc
+// @Summary Refund report
+// @Description Refunds issued in a range of days with their amount, reason, who canceled or rejected the order and whether the provider has confirmed them (admin only). Defaults to the last 30 days.
+// @Tags admin
+// @Security BearerAuth
+// @Produce json
+// @Param from query string false "First day, YYYY-MM-DD"
+// @Param to query string false "Last day, YYYY-MM-DD"
+// @Success 200 {object} []repository.Refund
+// @Failure 400 {object} response.Error
+// @Router /admin/reports/refunds [get]
+func GetRefundReport(c *gin.Context) {
+	from, to, ok := reportRange(c)
+	if !ok {
+		return
+	}
+	refunds, err := repository.GetRefundReport(from, to)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, refunds)
+}
@@ -0,0 +1,90 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/Anwarjondev/fast-food/internal/repository"
+	"github.com/Anwarjondev/fast-food/internal/response"
+	"github.com/gin-gonic/gin"
+)
+
+// RoleRequest represents the request body for granting a role
+type RoleRequest struct {
+	Role string `json:"role" binding:"required,oneof=customer cashier kitchen courier admin"`
+}
+
+// GetUserRoles godoc
+// @Summary Get user roles
+// @Description Get the roles granted to a user (admin only)
+// @Tags admin
+// @Security BearerAuth
+// @Produce json
+// @Param id path int true "User ID"
+// @Success 200 {object} map[string]interface{}
+// @Router /admin/users/{id}/roles [get]
+func GetUserRoles(c *gin.Context) {
+	userID, ok := pathID(c, "id")
+	if !ok {
+		return
+	}
+	roles, err := repository.GetUserRoles(userID)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"user_id": userID, "roles": roles})
+}
+
+// GrantRole godoc
+// @Summary Grant role
+// @Description Grant a role to a user (admin only)
+// @Tags admin
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param id path int true "User ID"
+// @Param request body RoleRequest true "Role"
+// @Success 200 {object} map[string]interface{}
+// @Router /admin/users/{id}/roles [post]
+func GrantRole(c *gin.Context) {
+	userID, ok := pathID(c, "id")
+	if !ok {
+		return
+	}
+	var req RoleRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+	if err := repository.GrantRole(userID, req.Role); err != nil {
+		respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Role granted"})
+}
+
+// RevokeRole godoc
+// @Summary Revoke role
+// @Description Revoke a role from a user (admin only)
+// @Tags admin
+// @Security BearerAuth
+// @Produce json
+// @Param id path int true "User ID"
+// @Param role path string true "Role"
+// @Success 200 {object} map[string]interface{}
+// @Router /admin/users/{id}/roles/{role} [delete]
+func RevokeRole(c *gin.Context) {
+	userID, ok := pathID(c, "id")
+	if !ok {
+		return
+	}
+	role := c.Param("role")
+	if role == repository.RoleAdmin && userID == c.GetInt("user_id") {
+		response.Abort(c, http.StatusBadRequest, "cannot_revoke_own_admin", "You cannot revoke your own admin role")
+		return
+	}
+	if err := repository.RevokeRole(userID, role); err != nil {
+		respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Role revoked"})
+}
@@ -0,0 +1,214 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/Anwarjondev/fast-food/internal/events"
+	"github.com/Anwarjondev/fast-food/internal/repository"
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+)
+
+const (
+	socketPingInterval = 30 * time.Second
+	socketPongWait     = 60 * time.Second
+	socketWriteWait    = 10 * time.Second
+	socketMaxMessage   = 4096
+)
+
+var upgrader = websocket.Upgrader{
+	// Sockets authenticate with a bearer token rather than cookies, so like
+	// the REST API they can be opened from any origin
+	CheckOrigin: func(r *http.Request) bool { return true },
+}
+
+// roleStatuses lists the statuses whose events a staff role gets. Roles that
+// are not listed get every event.
+var roleStatuses = map[string][]string{
+	repository.RoleKitchen: {
+		repository.StatusPaid, repository.StatusPlaced, repository.StatusAccepted, repository.StatusPreparing,
+		repository.StatusReady, repository.StatusCanceled, repository.StatusRejected,
+	},
+	repository.RoleCourier: {
+		repository.StatusReady, repository.StatusOutForDelivery, repository.StatusDelivered,
+	},
+}
+
+// SocketMessage is a message on the staff socket. The server sends "hello"
+// once connected, "reset" with the newest event ID when a reconnecting
+// client missed too many events to replay, "event" for every order event,
+// and "ack" or "error" in reply to each client message, echoing its id.
+// Clients send "subscribe" with the roles whose events they want, or "ping".
+type SocketMessage struct {
+	Type        string             `json:"type"`
+	ID          string             `json:"id,omitempty"`
+	Roles       []string           `json:"roles,omitempty"`
+	Event       *events.OrderEvent `json:"event,omitempty"`
+	LastEventID int64              `json:"last_event_id,omitempty"`
+	Error       string             `json:"error,omitempty"`
+}
+
+// wantsEvent reports whether any of roles gets events about status
+func wantsEvent(roles []string, status string) bool {
+	for _, role := range roles {
+		statuses, ok := roleStatuses[role]
+		if !ok || slices.Contains(statuses, status) {
+			return true
+		}
+	}
+	return false
+}
+
+// StaffSocket godoc
+// @Summary Staff dashboard socket
+// @Description WebSocket pushing new orders and status changes to staff screens. Each connection gets the events relevant to its roles and can narrow them with a subscribe message. Reconnect with last_event_id to replay missed events; replay may repeat events already received. When too many events were missed the server sends a reset message instead, after which the screen should reload its orders.
+// @Tags kitchen
+// @Security BearerAuth
+// @Param last_event_id query int false "Replay events after this ID"
+// @Success 101 {object} SocketMessage
+// @Failure 403 {object} response.Error
+// @Router /staff/ws [get]
+func StaffSocket(c *gin.Context) {
+	var roles []string
+	for _, role := range c.GetStringSlice("roles") {
+		if role != repository.RoleCustomer {
+			roles = append(roles, role)
+		}
+	}
+
+	// Subscribe before replaying so nothing published in between is lost
+	sub := events.Subscribe()
+	defer sub.Close()
+	missed, complete, err := missedEvents(lastEventID(c), 0, 0)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	var resetID int64
+	if !complete {
+		if resetID, err = repository.LastEventID(); err != nil {
+			respondError(c, err)
+			return
+		}
+	}
+
+	conn, err := upgrader.Upgrade(c.Writer, c.Request, nil)
+	if err != nil {
+		// Upgrade has already replied with an HTTP error
+		return
+	}
+	defer conn.Close()
+
+	done := make(chan struct{})
+	defer close(done)
+	incoming := make(chan SocketMessage)
+	go readSocket(conn, incoming, done)
+
+	subscribed := roles
+	send := func(m SocketMessage) bool {
+		conn.SetWriteDeadline(time.Now().Add(socketWriteWait))
+		return conn.WriteJSON(m) == nil
+	}
+	var seen seenEvents
+	sendEvent := func(e events.OrderEvent) bool {
+		if !wantsEvent(subscribed, e.Status) || !seen.add(e.ID) {
+			return true
+		}
+		return send(SocketMessage{Type: "event", Event: &e})
+	}
+
+	if !send(SocketMessage{Type: "hello", Roles: subscribed}) {
+		return
+	}
+	if !complete && !send(SocketMessage{Type: "reset", LastEventID: resetID}) {
+		return
+	}
+	for _, e := range missed {
+		if !sendEvent(e) {
+			return
+		}
+	}
+
+	ping := time.NewTicker(socketPingInterval)
+	defer ping.Stop()
+	for {
+		select {
+		case e, ok := <-sub.C:
+			if !ok {
+				// This connection fell behind; the client reconnects with
+				// the last event ID it got
+				conn.WriteControl(websocket.CloseMessage,
+					websocket.FormatCloseMessage(websocket.CloseTryAgainLater, "fell behind"),
+					time.Now().Add(socketWriteWait))
+				return
+			}
+			if !sendEvent(e) {
+				return
+			}
+		case m, ok := <-incoming:
+			if !ok {
+				return
+			}
+			reply := SocketMessage{Type: "ack", ID: m.ID}
+			switch m.Type {
+			case "subscribe":
+				wanted := []string{}
+				for _, role := range m.Roles {
+					if !slices.Contains(roles, role) {
+						reply = SocketMessage{Type: "error", ID: m.ID, Error: "you do not have the " + role + " role"}
+						break
+					}
+					wanted = append(wanted, role)
+				}
+				if reply.Type == "ack" {
+					if len(wanted) == 0 {
+						wanted = roles
+					}
+					subscribed = wanted
+					reply.Roles = subscribed
+				}
+			case "ping":
+			default:
+				reply = SocketMessage{Type: "error", ID: m.ID, Error: "unknown message type " + strconv.Quote(m.Type)}
+			}
+			if !send(reply) {
+				return
+			}
+		case <-ping.C:
+			if conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(socketWriteWait)) != nil {
+				return
+			}
+		}
+	}
+}
+
+// readSocket passes client messages to incoming until the connection fails
+// or stays silent, without even answering pings, for socketPongWait
+func readSocket(conn *websocket.Conn, incoming chan<- SocketMessage, done <-chan struct{}) {
+	defer close(incoming)
+	conn.SetReadLimit(socketMaxMessage)
+	conn.SetReadDeadline(time.Now().Add(socketPongWait))
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(socketPongWait))
+	})
+	for {
+		_, data, err := conn.ReadMessage()
+		if err != nil {
+			return
+		}
+		conn.SetReadDeadline(time.Now().Add(socketPongWait))
+		var m SocketMessage
+		if err := json.Unmarshal(data, &m); err != nil {
+			m = SocketMessage{}
+		}
+		select {
+		case incoming <- m:
+		case <-done:
+			return
+		}
+	}
+}
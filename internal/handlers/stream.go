@@ -0,0 +1,203 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Anwarjondev/fast-food/internal/events"
+	"github.com/Anwarjondev/fast-food/internal/repository"
+	"github.com/gin-gonic/gin"
+)
+
+// streamHeartbeat is how often an idle stream sends a comment so proxies do
+// not close it
+const streamHeartbeat = 15 * time.Second
+
+// StreamOrders godoc
+// @Summary Stream order events
+// @Description Server-Sent Events stream of order events: the user's own orders for customers, every order for staff. Send Last-Event-ID (or last_event_id) to replay events missed while disconnected; replay may repeat events already received. When too many events were missed the stream starts with a reset event instead, after which the client should reload the orders it shows.
+// @Tags orders
+// @Security BearerAuth
+// @Produce text/event-stream
+// @Param last_event_id query int false "Replay events after this ID"
+// @Success 200 {object} events.OrderEvent
+// @Router /orders/stream [get]
+func StreamOrders(c *gin.Context) {
+	userID := c.GetInt("user_id")
+	if isStaff(c) {
+		userID = 0
+	}
+	streamEvents(c, userID, 0)
+}
+
+// StreamOrder godoc
+// @Summary Stream events of an order
+// @Description Server-Sent Events stream of the status changes of one order. Send Last-Event-ID (or last_event_id) to replay events missed while disconnected; replay may repeat events already received. When too many events were missed the stream starts with a reset event instead, after which the client should reload the orders it shows.
+// @Tags orders
+// @Security BearerAuth
+// @Produce text/event-stream
+// @Param order_id path int true "Order ID"
+// @Param last_event_id query int false "Replay events after this ID"
+// @Success 200 {object} events.OrderEvent
+// @Failure 404 {object} response.Error
+// @Router /orders/{order_id}/stream [get]
+func StreamOrder(c *gin.Context) {
+	order, ok := findOrder(c)
+	if !ok {
+		return
+	}
+	streamEvents(c, 0, order.ID)
+}
+
+// lastEventID reads the ID of the last event a reconnecting client has seen
+func lastEventID(c *gin.Context) int64 {
+	value := c.GetHeader("Last-Event-ID")
+	if value == "" {
+		value = c.Query("last_event_id")
+	}
+	id, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || id < 0 {
+		return 0
+	}
+	return id
+}
+
+// replayOverlap is how far before the last event a client saw replay looks
+// for events that committed late
+const replayOverlap = 30 * time.Second
+
+// maxReplayedEvents caps how many events a reconnecting client is replayed.
+// A client that missed more is told to reload its state instead.
+const maxReplayedEvents = 500
+
+// missedEvents returns the events a client that last saw lastID may have
+// missed, oldest first, including some it may already have. lastID 0 means
+// the client is not resuming and has missed nothing. complete is false, with
+// no events, when more than maxReplayedEvents were missed.
+func missedEvents(lastID int64, userID, orderID int) (missed []events.OrderEvent, complete bool, err error) {
+	if lastID == 0 {
+		return nil, true, nil
+	}
+	after, err := repository.ReplayStart(lastID, replayOverlap)
+	if err != nil {
+		return nil, false, err
+	}
+	missed, err = repository.GetOrderEventsSince(after, userID, orderID, maxReplayedEvents+1)
+	if err != nil {
+		return nil, false, err
+	}
+	if len(missed) > maxReplayedEvents {
+		return nil, false, nil
+	}
+	return missed, true, nil
+}
+
+// seenEventsKept is how far below the highest event ID a connection sent it
+// still remembers which events it sent
+const seenEventsKept = 10000
+
+// seenEvents remembers the events a connection has sent. Events do not always
+// arrive in ID order, so an event with a lower ID than one already sent can
+// still be new.
+type seenEvents struct {
+	ids map[int64]struct{}
+	max int64
+}
+
+// add records an event and reports whether it had not been sent before
+func (s *seenEvents) add(id int64) bool {
+	if s.ids == nil {
+		s.ids = map[int64]struct{}{}
+	}
+	if _, ok := s.ids[id]; ok {
+		return false
+	}
+	s.ids[id] = struct{}{}
+	s.max = max(s.max, id)
+	if len(s.ids) > 2*seenEventsKept {
+		for old := range s.ids {
+			if old < s.max-seenEventsKept {
+				delete(s.ids, old)
+			}
+		}
+	}
+	return true
+}
+
+// streamEvents writes order events as Server-Sent Events until the client
+// goes away. userID and orderID narrow the events when they are not 0.
+func streamEvents(c *gin.Context, userID, orderID int) {
+	// Subscribe before replaying so nothing published in between is lost
+	sub := events.Subscribe()
+	defer sub.Close()
+
+	missed, complete, err := missedEvents(lastEventID(c), userID, orderID)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	var resetID int64
+	if !complete {
+		if resetID, err = repository.LastEventID(); err != nil {
+			respondError(c, err)
+			return
+		}
+	}
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no")
+	c.Status(http.StatusOK)
+
+	var seen seenEvents
+	write := func(e events.OrderEvent) bool {
+		if (userID != 0 && e.UserID != userID) || (orderID != 0 && e.OrderID != orderID) || !seen.add(e.ID) {
+			return true
+		}
+		data, err := json.Marshal(e)
+		if err != nil {
+			return false
+		}
+		if _, err := fmt.Fprintf(c.Writer, "id: %d\nevent: %s\ndata: %s\n\n", e.ID, e.Type, data); err != nil {
+			return false
+		}
+		return true
+	}
+	if !complete {
+		// Too much was missed to replay; the client reloads what it shows
+		// and carries on from the newest event
+		if _, err := fmt.Fprintf(c.Writer, "id: %d\nevent: reset\ndata: {}\n\n", resetID); err != nil {
+			return
+		}
+	}
+	for _, e := range missed {
+		if !write(e) {
+			return
+		}
+	}
+	c.Writer.Flush()
+
+	heartbeat := time.NewTicker(streamHeartbeat)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case <-c.Request.Context().Done():
+			return
+		case e, ok := <-sub.C:
+			// A closed subscription means this client fell behind; it
+			// reconnects and replays from the last event it got
+			if !ok || !write(e) {
+				return
+			}
+		case <-heartbeat.C:
+			if _, err := fmt.Fprint(c.Writer, ": ping\n\n"); err != nil {
+				return
+			}
+		}
+		c.Writer.Flush()
+	}
+}
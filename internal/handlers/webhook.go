@@ -0,0 +1,212 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/Anwarjondev/fast-food/internal/repository"
+	"github.com/Anwarjondev/fast-food/internal/utils"
+	"github.com/gin-gonic/gin"
+)
+
+// WebhookRequest represents the request body for creating or updating a
+// webhook. An empty event_types list subscribes to every event.
+type WebhookRequest struct {
+	URL        string   `json:"url" binding:"required,http_url,max=2000"`
+	Secret     string   `json:"secret" binding:"omitempty,min=16,max=200"`
+	EventTypes []string `json:"event_types" binding:"max=4,dive,oneof=order.created order.status_changed order.canceled order.completed"`
+	IsActive   *bool    `json:"is_active"`
+}
+
+func (r WebhookRequest) active() bool {
+	return r.IsActive == nil || *r.IsActive
+}
+
+// eventTypes returns the requested event types; a left out list is empty
+// rather than nil, which would be stored as NULL
+func (r WebhookRequest) eventTypes() []string {
+	if r.EventTypes == nil {
+		return []string{}
+	}
+	return r.EventTypes
+}
+
+// GetWebhooks godoc
+// @Summary List webhooks
+// @Description List webhook subscriptions without their secrets (admin only)
+// @Tags admin
+// @Security BearerAuth
+// @Produce json
+// @Success 200 {object} []repository.Webhook
+// @Router /admin/webhooks [get]
+func GetWebhooks(c *gin.Context) {
+	webhooks, err := repository.GetWebhooks()
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, webhooks)
+}
+
+// GetWebhook godoc
+// @Summary Get webhook
+// @Description Get a webhook subscription without its secret (admin only)
+// @Tags admin
+// @Security BearerAuth
+// @Produce json
+// @Param id path int true "Webhook ID"
+// @Success 200 {object} repository.Webhook
+// @Failure 404 {object} response.Error
+// @Router /admin/webhooks/{id} [get]
+func GetWebhook(c *gin.Context) {
+	id, ok := pathID(c, "id")
+	if !ok {
+		return
+	}
+	webhook, err := repository.GetWebhook(id)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, webhook)
+}
+
+// CreateWebhook godoc
+// @Summary Create webhook
+// @Description Subscribe a URL to order events. Without a secret one is generated; the secret is only returned here. (admin only)
+// @Tags admin
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param request body WebhookRequest true "Webhook"
+// @Success 201 {object} repository.Webhook
+// @Failure 400 {object} response.Error
+// @Router /admin/webhooks [post]
+func CreateWebhook(c *gin.Context) {
+	var req WebhookRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+	if req.Secret == "" {
+		secret, err := utils.GenerateToken()
+		if err != nil {
+			respondError(c, err)
+			return
+		}
+		req.Secret = secret
+	}
+	webhook, err := repository.CreateWebhook(req.URL, req.Secret, req.eventTypes(), req.active())
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, webhook)
+}
+
+// UpdateWebhook godoc
+// @Summary Update webhook
+// @Description Change the URL, event types or active flag of a webhook, and its secret when one is given (admin only)
+// @Tags admin
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param id path int true "Webhook ID"
+// @Param request body WebhookRequest true "Webhook"
+// @Success 200 {object} repository.Webhook
+// @Failure 404 {object} response.Error
+// @Router /admin/webhooks/{id} [put]
+func UpdateWebhook(c *gin.Context) {
+	id, ok := pathID(c, "id")
+	if !ok {
+		return
+	}
+	var req WebhookRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+	webhook, err := repository.UpdateWebhook(id, req.URL, req.Secret, req.eventTypes(), req.active())
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, webhook)
+}
+
+// DeleteWebhook godoc
+// @Summary Delete webhook
+// @Description Delete a webhook and cancel its pending deliveries; the delivery log is kept (admin only)
+// @Tags admin
+// @Security BearerAuth
+// @Produce json
+// @Param id path int true "Webhook ID"
+// @Success 200 {object} map[string]interface{}
+// @Failure 404 {object} response.Error
+// @Router /admin/webhooks/{id} [delete]
+func DeleteWebhook(c *gin.Context) {
+	id, ok := pathID(c, "id")
+	if !ok {
+		return
+	}
+	if err := repository.DeleteWebhook(id); err != nil {
+		respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Webhook deleted"})
+}
+
+// GetWebhookDeliveries godoc
+// @Summary Webhook delivery log
+// @Description List the latest deliveries of a webhook, newest first (admin only)
+// @Tags admin
+// @Security BearerAuth
+// @Produce json
+// @Param id path int true "Webhook ID"
+// @Param status query string false "Only deliveries in this status (pending, delivered, failed, canceled)"
+// @Param limit query int false "How many deliveries to return (default 50, at most 200)"
+// @Success 200 {object} []repository.WebhookDelivery
+// @Failure 404 {object} response.Error
+// @Router /admin/webhooks/{id}/deliveries [get]
+func GetWebhookDeliveries(c *gin.Context) {
+	id, ok := pathID(c, "id")
+	if !ok {
+		return
+	}
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	if err != nil || limit < 1 || limit > 200 {
+		limit = 50
+	}
+	deliveries, err := repository.GetWebhookDeliveries(id, c.Query("status"), limit)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, deliveries)
+}
+
+// RetryWebhookDelivery godoc
+// @Summary Retry webhook delivery
+// @Description Queue a delivery to be sent again right away (admin only)
+// @Tags admin
+// @Security BearerAuth
+// @Produce json
+// @Param id path int true "Webhook ID"
+// @Param delivery_id path int true "Delivery ID"
+// @Success 200 {object} repository.WebhookDelivery
+// @Failure 404 {object} response.Error
+// @Router /admin/webhooks/{id}/deliveries/{delivery_id}/retry [post]
+func RetryWebhookDelivery(c *gin.Context) {
+	id, ok := pathID(c, "id")
+	if !ok {
+		return
+	}
+	deliveryID, ok := pathID(c, "delivery_id")
+	if !ok {
+		return
+	}
+	delivery, err := repository.RetryWebhookDelivery(id, deliveryID)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, delivery)
+}
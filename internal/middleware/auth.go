@@ -6,44 +6,102 @@ import (
 	"strings"
 
 	"github.com/Anwarjondev/fast-food/internal/repository"
+	"github.com/Anwarjondev/fast-food/internal/response"
+	"github.com/Anwarjondev/fast-food/internal/token"
 	"github.com/gin-gonic/gin"
 )
 
-func AuthMiddleware() gin.HandlerFunc {
+// AuthMiddleware resolves the bearer token to a user. With a JWT manager the
+// token is verified locally and only its session is checked for revocation;
+// otherwise the token is looked up in the sessions table.
+func AuthMiddleware(jwtManager *token.Manager) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		authHeader := c.GetHeader("Authorization")
 		if authHeader == "" || !strings.HasPrefix(authHeader, "Bearer ") {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
-			c.Abort()
+			response.Abort(c, http.StatusUnauthorized, "unauthorized", "Unauthorized")
 			return
 		}
-		token := strings.TrimPrefix(authHeader, "Bearer ")
-		user, err := repository.GetUserByToken(token)
+		bearer := strings.TrimPrefix(authHeader, "Bearer ")
+
+		if jwtManager != nil {
+			claims, err := jwtManager.Verify(bearer)
+			if err != nil {
+				response.Abort(c, http.StatusUnauthorized, "unauthorized", "Unauthorized")
+				return
+			}
+			userID, err := claims.UserID()
+			if err != nil {
+				response.Abort(c, http.StatusUnauthorized, "unauthorized", "Unauthorized")
+				return
+			}
+			live, err := repository.SessionLive(userID, claims.SessionID)
+			if err != nil || !live {
+				response.Abort(c, http.StatusUnauthorized, "unauthorized", "Unauthorized")
+				return
+			}
+			c.Set("user_id", userID)
+			c.Set("session_id", claims.SessionID)
+			c.Set("roles", claims.Roles)
+			c.Next()
+			return
+		}
+
+		user, err := repository.GetUserByToken(bearer)
 		if err != nil {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
-			c.Abort()
+			response.Abort(c, http.StatusUnauthorized, "unauthorized", "Unauthorized")
 			return
 		}
 		c.Set("user_id", user.ID)
+		c.Set("session_id", user.SessionID)
+		c.Set("roles", []string(user.Roles))
 		c.Next()
 	}
 }
 
+// QueryToken lets clients that cannot set headers, such as browser
+// EventSource and WebSocket, pass the access token as the access_token query
+// parameter. It must run before AuthMiddleware and only on the routes that
+// need it, since query strings end up in access logs.
+func QueryToken() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.GetHeader("Authorization") == "" {
+			if t := c.Query("access_token"); t != "" {
+				c.Request.Header.Set("Authorization", "Bearer "+t)
+			}
+		}
+		c.Next()
+	}
+}
+
+// RequireRole must run after AuthMiddleware and only lets through users
+// holding at least one of the given roles
+func RequireRole(roles ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		for _, have := range c.GetStringSlice("roles") {
+			for _, want := range roles {
+				if have == want {
+					c.Next()
+					return
+				}
+			}
+		}
+		response.Abort(c, http.StatusForbidden, "forbidden", "Forbidden")
+	}
+}
+
 // ConfirmationMiddleware is a middleware that gets the user ID from the X-User-ID header
 // This is used for confirmation endpoints where the user isn't authenticated yet
 func ConfirmationMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		userIDStr := c.GetHeader("X-User-ID")
 		if userIDStr == "" {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "User ID is required"})
-			c.Abort()
+			response.Abort(c, http.StatusBadRequest, "invalid_user_id", "User ID is required")
 			return
 		}
 
 		userID, err := strconv.Atoi(userIDStr)
 		if err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
-			c.Abort()
+			response.Abort(c, http.StatusBadRequest, "invalid_user_id", "Invalid user ID")
 			return
 		}
 
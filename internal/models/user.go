@@ -1,15 +1,19 @@
 package models
 
+import "time"
+
 type User struct {
 	ID        int    `db:"id"`
 	Email     string `db:"email"`
 	Password  string `db:"password"`
 	Is_Active bool   `db:"is_active"`
-	Token     string `db:"token"`
 }
 type Confirm struct {
-	ID        int  `db:"id"`
-	Code      int  `db:"code"`
-	User_id   int  `db:"user_id"`
-	Is_Passed bool `db:"is_passed"`
+	ID        int       `db:"id"`
+	User_id   int       `db:"user_id"`
+	CodeHash  string    `db:"code_hash"`
+	Purpose   string    `db:"purpose"`
+	ExpiresAt time.Time `db:"expires_at"`
+	Attempts  int       `db:"attempts"`
+	Is_Passed bool      `db:"is_passed"`
 }
@@ -0,0 +1,154 @@
+// Package money represents amounts as integer minor units (cents, tiyin, ...)
+// of an ISO 4217 currency, so prices add up exactly.
+package money
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Money is an amount in the minor unit of Currency. Arithmetic on amounts of
+// different currencies is a programming error and panics; check currencies
+// where they come from user input.
+type Money struct {
+	Amount   int64  `json:"amount" db:"amount"`
+	Currency string `json:"currency" db:"currency"`
+}
+
+// New returns amount minor units of currency
+func New(amount int64, currency string) Money {
+	return Money{Amount: amount, Currency: currency}
+}
+
+// Zero returns no money in currency
+func Zero(currency string) Money {
+	return Money{Currency: currency}
+}
+
+// exponents lists the ISO 4217 currencies whose minor unit is not 1/100
+var exponents = map[string]int{
+	"BIF": 0, "CLP": 0, "DJF": 0, "GNF": 0, "ISK": 0, "JPY": 0, "KMF": 0, "KRW": 0,
+	"PYG": 0, "RWF": 0, "UGX": 0, "VND": 0, "VUV": 0, "XAF": 0, "XOF": 0, "XPF": 0,
+	"BHD": 3, "IQD": 3, "JOD": 3, "KWD": 3, "LYD": 3, "OMR": 3, "TND": 3,
+}
+
+// Exponent returns how many decimal places the minor unit of currency has
+func Exponent(currency string) int {
+	if e, ok := exponents[currency]; ok {
+		return e
+	}
+	return 2
+}
+
+func (m Money) mustMatch(o Money) {
+	if m.Currency != o.Currency {
+		panic(fmt.Sprintf("money: currency mismatch %s and %s", m.Currency, o.Currency))
+	}
+}
+
+func (m Money) Add(o Money) Money {
+	m.mustMatch(o)
+	return Money{Amount: m.Amount + o.Amount, Currency: m.Currency}
+}
+
+func (m Money) Sub(o Money) Money {
+	m.mustMatch(o)
+	return Money{Amount: m.Amount - o.Amount, Currency: m.Currency}
+}
+
+// Mul returns m times n, e.g. a line total from a unit price
+func (m Money) Mul(n int) Money {
+	return Money{Amount: m.Amount * int64(n), Currency: m.Currency}
+}
+
+// Percent returns p percent of m, rounded half away from zero to the minor unit
+func (m Money) Percent(p float64) Money {
+	return Money{Amount: int64(math.Round(float64(m.Amount) * p / 100)), Currency: m.Currency}
+}
+
+// IncludedPercent returns the part of m that is p percent on top of a net
+// amount, e.g. the tax contained in a tax-inclusive price, rounded half away
+// from zero to the minor unit
+func (m Money) IncludedPercent(p float64) Money {
+	return Money{Amount: int64(math.Round(float64(m.Amount) * p / (100 + p))), Currency: m.Currency}
+}
+
+// Allocate splits m into parts proportional to weights, which must be in the
+// currency of m and not negative. The parts add up to m exactly; the minor
+// units left over by rounding down go to the first parts with a weight.
+// When every weight is zero the whole of m goes to the first part.
+func (m Money) Allocate(weights []Money) []Money {
+	parts := make([]Money, len(weights))
+	var total int64
+	for i, w := range weights {
+		m.mustMatch(w)
+		parts[i] = Zero(m.Currency)
+		total += w.Amount
+	}
+	if len(parts) == 0 {
+		return parts
+	}
+	if total == 0 {
+		parts[0] = m
+		return parts
+	}
+	left := m.Amount
+	for i, w := range weights {
+		parts[i].Amount = m.Amount * w.Amount / total
+		left -= parts[i].Amount
+	}
+	for i := 0; left != 0; i = (i + 1) % len(parts) {
+		if weights[i].Amount == 0 {
+			continue
+		}
+		if left > 0 {
+			parts[i].Amount++
+			left--
+		} else {
+			parts[i].Amount--
+			left++
+		}
+	}
+	return parts
+}
+
+// Min returns the smaller of m and o
+func (m Money) Min(o Money) Money {
+	m.mustMatch(o)
+	if o.Amount < m.Amount {
+		return o
+	}
+	return m
+}
+
+func (m Money) LessThan(o Money) bool {
+	m.mustMatch(o)
+	return m.Amount < o.Amount
+}
+
+func (m Money) IsZero() bool {
+	return m.Amount == 0
+}
+
+// Decimal formats the amount in major units, e.g. "12.50"
+func (m Money) Decimal() string {
+	exp := Exponent(m.Currency)
+	if exp == 0 {
+		return strconv.FormatInt(m.Amount, 10)
+	}
+	sign := ""
+	amount := m.Amount
+	if amount < 0 {
+		sign = "-"
+		amount = -amount
+	}
+	digits := fmt.Sprintf("%0*d", exp+1, amount)
+	return sign + digits[:len(digits)-exp] + "." + digits[len(digits)-exp:]
+}
+
+// String formats m for people and logs, e.g. "12.50 USD"
+func (m Money) String() string {
+	return strings.TrimSpace(m.Decimal() + " " + m.Currency)
+}
@@ -0,0 +1,115 @@
+package money
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestPercent(t *testing.T) {
+	tests := []struct {
+		amount int64
+		p      float64
+		want   int64
+	}{
+		{1000, 12.5, 125},
+		{1005, 10, 101},
+		{-1005, 10, -101},
+		{1004, 10, 100},
+		{999, 0, 0},
+		{0, 15, 0},
+	}
+	for _, tt := range tests {
+		if got := New(tt.amount, "USD").Percent(tt.p); got != New(tt.want, "USD") {
+			t.Errorf("%d.Percent(%v) = %v, want %d", tt.amount, tt.p, got, tt.want)
+		}
+	}
+}
+
+func TestDecimal(t *testing.T) {
+	tests := []struct {
+		m    Money
+		want string
+	}{
+		{New(1250, "USD"), "12.50 USD"},
+		{New(5, "USD"), "0.05 USD"},
+		{New(-5, "USD"), "-0.05 USD"},
+		{New(0, "EUR"), "0.00 EUR"},
+		{New(1200, "JPY"), "1200 JPY"},
+		{New(1234, "KWD"), "1.234 KWD"},
+	}
+	for _, tt := range tests {
+		if got := tt.m.String(); got != tt.want {
+			t.Errorf("%#v.String() = %q, want %q", tt.m, got, tt.want)
+		}
+	}
+}
+
+func TestIncludedPercent(t *testing.T) {
+	tests := []struct {
+		amount int64
+		p      float64
+		want   int64
+	}{
+		{1120, 12, 120},
+		{1000, 12, 107},
+		{1150, 15, 150},
+		{1000, 0, 0},
+		{0, 12, 0},
+	}
+	for _, tt := range tests {
+		if got := New(tt.amount, "USD").IncludedPercent(tt.p); got != New(tt.want, "USD") {
+			t.Errorf("%d.IncludedPercent(%v) = %v, want %d", tt.amount, tt.p, got, tt.want)
+		}
+	}
+}
+
+func TestAllocate(t *testing.T) {
+	tests := []struct {
+		name    string
+		amount  int64
+		weights []int64
+		want    []int64
+	}{
+		{"even split", 90, []int64{1, 1, 1}, []int64{30, 30, 30}},
+		{"leftover to first parts", 100, []int64{1, 1, 1}, []int64{34, 33, 33}},
+		{"proportional", 7, []int64{1, 2}, []int64{3, 4}},
+		{"leftover skips zero weights", 101, []int64{0, 1, 1}, []int64{0, 51, 50}},
+		{"negative amount", -100, []int64{1, 1, 1}, []int64{-34, -33, -33}},
+		{"all weights zero", 100, []int64{0, 0}, []int64{100, 0}},
+		{"single part", 57, []int64{3}, []int64{57}},
+		{"no parts", 100, nil, []int64{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			weights := make([]Money, len(tt.weights))
+			for i, w := range tt.weights {
+				weights[i] = New(w, "USD")
+			}
+			parts := New(tt.amount, "USD").Allocate(weights)
+			got := make([]int64, len(parts))
+			var sum int64
+			for i, p := range parts {
+				if p.Currency != "USD" {
+					t.Errorf("part %d currency = %q, want USD", i, p.Currency)
+				}
+				got[i] = p.Amount
+				sum += p.Amount
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("Allocate = %v, want %v", got, tt.want)
+			}
+			if len(parts) > 0 && sum != tt.amount {
+				t.Errorf("parts add up to %d, want %d", sum, tt.amount)
+			}
+		})
+	}
+}
+
+func TestAllocateCurrencyMismatch(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("Allocate with a weight in another currency did not panic")
+		}
+	}()
+	New(100, "USD").Allocate([]Money{New(1, "USD"), New(1, "EUR")})
+}
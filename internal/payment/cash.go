@@ -0,0 +1,40 @@
+package payment
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/Anwarjondev/fast-food/internal/money"
+)
+
+// CashProvider takes payment in cash from the courier or at the counter.
+// There is nothing to hold up front, so orders go straight to the kitchen
+// and capturing records that the cash was collected.
+type CashProvider struct{}
+
+func (CashProvider) Name() string {
+	return "cash"
+}
+
+func (CashProvider) Authorize(ctx context.Context, req AuthorizeRequest) (Result, error) {
+	return Result{Reference: fmt.Sprintf("cash-%d", req.OrderID), Status: StatusDeferred}, nil
+}
+
+func (CashProvider) Capture(ctx context.Context, reference string, amount money.Money) (Result, error) {
+	return Result{Reference: reference, Status: StatusCaptured}, nil
+}
+
+// Void has nothing to release, as no cash was taken yet
+func (CashProvider) Void(ctx context.Context, reference string) (Result, error) {
+	return Result{Reference: reference, Status: StatusVoided}, nil
+}
+
+// Refund records that cash was handed back
+func (CashProvider) Refund(ctx context.Context, reference string, amount money.Money) (Result, error) {
+	return Result{Reference: reference, Status: StatusRefunded}, nil
+}
+
+func (CashProvider) VerifyWebhook(header http.Header, body []byte) (WebhookEvent, error) {
+	return WebhookEvent{}, ErrWebhooksUnsupported
+}
@@ -0,0 +1,75 @@
+package payment
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/Anwarjondev/fast-food/internal/money"
+	"github.com/Anwarjondev/fast-food/internal/utils"
+)
+
+// Test card tokens understood by FakeCardProvider
+const (
+	FakeCardApproved = "tok_approved"
+	FakeCardDeclined = "tok_declined"
+	FakeCardPending  = "tok_pending"
+)
+
+// fakeCardTolerance is how old a webhook timestamp may be
+const fakeCardTolerance = 5 * time.Minute
+
+// FakeCardProvider is a card acquirer for development and tests. It approves
+// FakeCardApproved, declines FakeCardDeclined and every other token, and
+// leaves FakeCardPending undecided until a webhook settles it. Webhooks are
+// signed like our own outgoing webhooks, with Secret, in the
+// X-Payment-Signature header.
+type FakeCardProvider struct {
+	Secret string
+}
+
+func (FakeCardProvider) Name() string {
+	return "card"
+}
+
+func (FakeCardProvider) Authorize(ctx context.Context, req AuthorizeRequest) (Result, error) {
+	reference, err := utils.GenerateToken()
+	if err != nil {
+		return Result{}, err
+	}
+	reference = "fake_" + reference[:24]
+	switch req.Token {
+	case FakeCardApproved:
+		return Result{Reference: reference, Status: StatusAuthorized}, nil
+	case FakeCardPending:
+		return Result{Reference: reference, Status: StatusPending}, nil
+	case FakeCardDeclined:
+		return Result{Reference: reference, Status: StatusDeclined, Message: "card declined"}, nil
+	}
+	return Result{Reference: reference, Status: StatusDeclined, Message: "unknown card token"}, nil
+}
+
+func (FakeCardProvider) Capture(ctx context.Context, reference string, amount money.Money) (Result, error) {
+	return Result{Reference: reference, Status: StatusCaptured}, nil
+}
+
+func (FakeCardProvider) Void(ctx context.Context, reference string) (Result, error) {
+	return Result{Reference: reference, Status: StatusVoided}, nil
+}
+
+func (FakeCardProvider) Refund(ctx context.Context, reference string, amount money.Money) (Result, error) {
+	return Result{Reference: reference, Status: StatusRefunded}, nil
+}
+
+// VerifyWebhook checks the signature of a webhook with a JSON WebhookEvent body
+func (p FakeCardProvider) VerifyWebhook(header http.Header, body []byte) (WebhookEvent, error) {
+	var e WebhookEvent
+	if p.Secret == "" || !utils.VerifyWebhook(p.Secret, header.Get("X-Payment-Signature"), body, fakeCardTolerance) {
+		return e, ErrInvalidSignature
+	}
+	if err := json.Unmarshal(body, &e); err != nil || e.Reference == "" {
+		return e, ErrInvalidWebhook
+	}
+	return e, nil
+}
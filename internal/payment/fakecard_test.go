@@ -0,0 +1,77 @@
+package payment
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Anwarjondev/fast-food/internal/money"
+	"github.com/Anwarjondev/fast-food/internal/utils"
+)
+
+func TestFakeCardAuthorize(t *testing.T) {
+	tests := []struct {
+		token   string
+		status  string
+		message string
+	}{
+		{FakeCardApproved, StatusAuthorized, ""},
+		{FakeCardPending, StatusPending, ""},
+		{FakeCardDeclined, StatusDeclined, "card declined"},
+		{"tok_other", StatusDeclined, "unknown card token"},
+		{"", StatusDeclined, "unknown card token"},
+	}
+	p := FakeCardProvider{Secret: "secret"}
+	for _, tt := range tests {
+		res, err := p.Authorize(context.Background(), AuthorizeRequest{OrderID: 1, Amount: money.New(1000, "USD"), Token: tt.token})
+		if err != nil {
+			t.Fatalf("Authorize(%q): %v", tt.token, err)
+		}
+		if res.Status != tt.status || res.Message != tt.message {
+			t.Errorf("Authorize(%q) = %q %q, want %q %q", tt.token, res.Status, res.Message, tt.status, tt.message)
+		}
+		if !strings.HasPrefix(res.Reference, "fake_") {
+			t.Errorf("Authorize(%q) reference = %q, want a fake_ prefix", tt.token, res.Reference)
+		}
+	}
+}
+
+func TestFakeCardVerifyWebhook(t *testing.T) {
+	body := []byte(`{"reference":"fake_abc","status":"authorized"}`)
+	now := time.Now().Unix()
+	tests := []struct {
+		name      string
+		secret    string
+		signature string
+		body      []byte
+		err       error
+	}{
+		{"valid", "secret", utils.SignWebhook("secret", now, body), body, nil},
+		{"wrong secret", "secret", utils.SignWebhook("other", now, body), body, ErrInvalidSignature},
+		{"tampered body", "secret", utils.SignWebhook("secret", now, body), []byte(`{"reference":"fake_abc","status":"declined"}`), ErrInvalidSignature},
+		{"stale timestamp", "secret", utils.SignWebhook("secret", now-int64((6*time.Minute).Seconds()), body), body, ErrInvalidSignature},
+		{"future timestamp", "secret", utils.SignWebhook("secret", now+int64((6*time.Minute).Seconds()), body), body, ErrInvalidSignature},
+		{"missing signature", "secret", "", body, ErrInvalidSignature},
+		{"no secret configured", "", utils.SignWebhook("", now, body), body, ErrInvalidSignature},
+		{"bad json", "secret", utils.SignWebhook("secret", now, []byte(`{`)), []byte(`{`), ErrInvalidWebhook},
+		{"no reference", "secret", utils.SignWebhook("secret", now, []byte(`{"status":"authorized"}`)), []byte(`{"status":"authorized"}`), ErrInvalidWebhook},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			header := http.Header{}
+			if tt.signature != "" {
+				header.Set("X-Payment-Signature", tt.signature)
+			}
+			e, err := FakeCardProvider{Secret: tt.secret}.VerifyWebhook(header, tt.body)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("VerifyWebhook error = %v, want %v", err, tt.err)
+			}
+			if err == nil && (e.Reference != "fake_abc" || e.Status != StatusAuthorized) {
+				t.Errorf("VerifyWebhook = %+v, want reference fake_abc and status authorized", e)
+			}
+		})
+	}
+}
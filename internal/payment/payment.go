@@ -0,0 +1,102 @@
+// Package payment charges for orders through pluggable providers. Providers
+// are registered once at startup under the payment method clients choose.
+package payment
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sort"
+
+	"github.com/Anwarjondev/fast-food/internal/money"
+)
+
+// Statuses a provider reports for a payment
+const (
+	// StatusAuthorized means the amount is held and can be captured
+	StatusAuthorized = "authorized"
+	// StatusDeferred means there is nothing to hold, e.g. cash on delivery;
+	// the amount is collected when the order is delivered
+	StatusDeferred = "deferred"
+	// StatusPending means the provider decides later and reports the outcome
+	// through a webhook
+	StatusPending  = "pending"
+	StatusDeclined = "declined"
+	StatusCaptured = "captured"
+	StatusRefunded = "refunded"
+	// StatusVoided means an authorized amount was released without being
+	// captured
+	StatusVoided = "voided"
+)
+
+var (
+	ErrUnknownMethod       = errors.New("payment method is not available")
+	ErrInvalidSignature    = errors.New("payment webhook signature is invalid")
+	ErrInvalidWebhook      = errors.New("payment webhook body is invalid")
+	ErrWebhooksUnsupported = errors.New("payment method does not send webhooks")
+)
+
+// AuthorizeRequest asks a provider to hold Amount for an order. Token is the
+// payment token the client obtained from the provider, if it needs one.
+type AuthorizeRequest struct {
+	OrderID int
+	Amount  money.Money
+	Token   string
+}
+
+// Result is the outcome of a provider call. Reference identifies the payment
+// at the provider and Message explains a decline.
+type Result struct {
+	Reference string
+	Status    string
+	Message   string
+}
+
+// WebhookEvent is a payment update pushed by a provider
+type WebhookEvent struct {
+	Reference string `json:"reference"`
+	Status    string `json:"status"`
+	Message   string `json:"message"`
+}
+
+// Provider authorizes, captures and refunds payments. Authorize may return
+// StatusAuthorized, StatusDeferred, StatusPending or StatusDeclined; an error
+// means the provider could not be reached or failed. Capture may take less
+// than was authorized, and Void releases an authorized payment that is not
+// captured at all; only captured payments are refunded. VerifyWebhook checks
+// that a webhook request came from the provider and decodes it.
+type Provider interface {
+	Name() string
+	Authorize(ctx context.Context, req AuthorizeRequest) (Result, error)
+	Capture(ctx context.Context, reference string, amount money.Money) (Result, error)
+	Void(ctx context.Context, reference string) (Result, error)
+	Refund(ctx context.Context, reference string, amount money.Money) (Result, error)
+	VerifyWebhook(header http.Header, body []byte) (WebhookEvent, error)
+}
+
+var providers = map[string]Provider{}
+
+// Register makes p available under its name. It is not safe to call once
+// requests are being served.
+func Register(p Provider) {
+	providers[p.Name()] = p
+}
+
+// Get returns the provider registered for method
+func Get(method string) (Provider, error) {
+	p, ok := providers[method]
+	if !ok {
+		return nil, ErrUnknownMethod
+	}
+	return p, nil
+}
+
+// Methods lists the registered payment methods
+func Methods() []string {
+	methods := make([]string, 0, len(providers))
+	for name := range providers {
+		methods = append(methods, name)
+	}
+	sort.Strings(methods)
+	return methods
+}
@@ -0,0 +1,316 @@
+package repository
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/Anwarjondev/fast-food/internal/db"
+	"github.com/Anwarjondev/fast-food/internal/geo"
+	"github.com/jmoiron/sqlx"
+)
+
+var (
+	ErrAddressNotFound    = errors.New("address not found")
+	ErrAddressRequired    = errors.New("delivery orders need an address")
+	ErrAddressOutOfRange  = errors.New("address is too far away for delivery")
+	ErrInvalidFulfillment = errors.New("fulfillment must be delivery or pickup")
+	ErrDefaultAddressKept = errors.New("the default address cannot be unset, make another address the default instead")
+)
+
+// How an order reaches the customer
+const (
+	FulfillmentDelivery = "delivery"
+	FulfillmentPickup   = "pickup"
+)
+
+// Address is an entry of a user's address book. CourierNotes tell the
+// courier how to find the door.
+type Address struct {
+	ID           int       `json:"id" db:"id"`
+	UserID       int       `json:"user_id" db:"user_id"`
+	Label        string    `json:"label" db:"label"`
+	Line1        string    `json:"line1" db:"line1"`
+	Line2        string    `json:"line2" db:"line2"`
+	City         string    `json:"city" db:"city"`
+	PostalCode   string    `json:"postal_code" db:"postal_code"`
+	Phone        string    `json:"phone" db:"phone"`
+	Latitude     float64   `json:"latitude" db:"latitude"`
+	Longitude    float64   `json:"longitude" db:"longitude"`
+	CourierNotes string    `json:"courier_notes" db:"courier_notes"`
+	IsDefault    bool      `json:"is_default" db:"is_default"`
+	CreatedAt    time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at" db:"updated_at"`
+}
+
+const addressColumns = `id, user_id, label, line1, line2, city, postal_code, phone,
+	latitude, longitude, courier_notes, is_default, created_at, updated_at`
+
+func (a Address) point() geo.Point {
+	return geo.Point{Lat: a.Latitude, Lng: a.Longitude}
+}
+
+// OrderAddress is the copy of an address kept on a delivery order. It is
+// stored as JSON.
+type OrderAddress struct {
+	Label        string  `json:"label"`
+	Line1        string  `json:"line1"`
+	Line2        string  `json:"line2"`
+	City         string  `json:"city"`
+	PostalCode   string  `json:"postal_code"`
+	Phone        string  `json:"phone"`
+	Latitude     float64 `json:"latitude"`
+	Longitude    float64 `json:"longitude"`
+	CourierNotes string  `json:"courier_notes"`
+}
+
+func (a Address) snapshot() OrderAddress {
+	return OrderAddress{
+		Label:        a.Label,
+		Line1:        a.Line1,
+		Line2:        a.Line2,
+		City:         a.City,
+		PostalCode:   a.PostalCode,
+		Phone:        a.Phone,
+		Latitude:     a.Latitude,
+		Longitude:    a.Longitude,
+		CourierNotes: a.CourierNotes,
+	}
+}
+
+func (a OrderAddress) Value() (driver.Value, error) {
+	return json.Marshal(a)
+}
+
+func (a *OrderAddress) Scan(src any) error {
+	switch v := src.(type) {
+	case []byte:
+		return json.Unmarshal(v, a)
+	case string:
+		return json.Unmarshal([]byte(v), a)
+	}
+	return fmt.Errorf("cannot scan %T into OrderAddress", src)
+}
+
+// DeliveryRates price delivery by the straight-line distance from the
+// kitchen: BaseFee plus FeePerKm for every kilometer, both in minor units of
+// the order currency. Addresses further than MaxDistanceKm are not delivered
+// to; 0 means no limit.
+type DeliveryRates struct {
+	Kitchen       geo.Point
+	BaseFee       int64
+	FeePerKm      int64
+	MaxDistanceKm float64
+}
+
+// fee returns the delivery fee to an address, or ErrAddressOutOfRange
+func (r DeliveryRates) fee(to geo.Point) (int64, error) {
+	distance := geo.DistanceKm(r.Kitchen, to)
+	if r.MaxDistanceKm > 0 && distance > r.MaxDistanceKm {
+		return 0, ErrAddressOutOfRange
+	}
+	return r.BaseFee + int64(math.Round(distance*float64(r.FeePerKm))), nil
+}
+
+func GetAddresses(userID int) ([]Address, error) {
+	addresses := []Address{}
+	err := db.DB.Select(&addresses, `
+		SELECT `+addressColumns+` FROM addresses WHERE user_id = $1 ORDER BY is_default DESC, id
+	`, userID)
+	return addresses, err
+}
+
+// GetAddress returns an address of a user; other users' addresses are not
+// found
+func GetAddress(userID, id int) (Address, error) {
+	var address Address
+	err := db.DB.Get(&address, `SELECT `+addressColumns+` FROM addresses WHERE id = $1 AND user_id = $2`, id, userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return address, ErrAddressNotFound
+	}
+	return address, err
+}
+
+// clearDefaultAddress unmarks the user's default address, if any. Changes to
+// the default lock the user with lockUser first, so they do not race each
+// other into the unique index on the default.
+func clearDefaultAddress(tx *sqlx.Tx, userID int) error {
+	_, err := tx.Exec(`UPDATE addresses SET is_default = false WHERE user_id = $1 AND is_default`, userID)
+	return err
+}
+
+// CreateAddress adds an address to a user's book. The first address of a
+// user is their default, and a new default replaces the old one.
+func CreateAddress(a Address) (Address, error) {
+	tx, err := db.DB.Beginx()
+	if err != nil {
+		return Address{}, err
+	}
+	// Locking the user keeps two first addresses from both becoming the default
+	if err := lockUser(tx, a.UserID); err != nil {
+		tx.Rollback()
+		return Address{}, err
+	}
+	var count int
+	if err := tx.Get(&count, `SELECT count(*) FROM addresses WHERE user_id = $1`, a.UserID); err != nil {
+		tx.Rollback()
+		return Address{}, err
+	}
+	a.IsDefault = a.IsDefault || count == 0
+	if a.IsDefault {
+		if err := clearDefaultAddress(tx, a.UserID); err != nil {
+			tx.Rollback()
+			return Address{}, err
+		}
+	}
+	var created Address
+	err = tx.Get(&created, `
+		INSERT INTO addresses (user_id, label, line1, line2, city, postal_code, phone, latitude, longitude,
+			courier_notes, is_default)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+		RETURNING `+addressColumns,
+		a.UserID, a.Label, a.Line1, a.Line2, a.City, a.PostalCode, a.Phone, a.Latitude, a.Longitude,
+		a.CourierNotes, a.IsDefault)
+	if err != nil {
+		tx.Rollback()
+		return created, err
+	}
+	return created, tx.Commit()
+}
+
+// UpdateAddress changes an address of a user. Orders placed with it keep the
+// address they were placed with. makeDefault true makes it the default and
+// nil leaves the default as it is; false fails with ErrDefaultAddressKept
+// for the default address, so the user is never left without one.
+func UpdateAddress(a Address, makeDefault *bool) (Address, error) {
+	tx, err := db.DB.Beginx()
+	if err != nil {
+		return Address{}, err
+	}
+	if err := lockUser(tx, a.UserID); err != nil {
+		tx.Rollback()
+		return Address{}, err
+	}
+	var isDefault bool
+	err = tx.Get(&isDefault, `SELECT is_default FROM addresses WHERE id = $1 AND user_id = $2 FOR UPDATE`, a.ID, a.UserID)
+	if errors.Is(err, sql.ErrNoRows) {
+		tx.Rollback()
+		return Address{}, ErrAddressNotFound
+	}
+	if err != nil {
+		tx.Rollback()
+		return Address{}, err
+	}
+	if makeDefault != nil && !*makeDefault && isDefault {
+		tx.Rollback()
+		return Address{}, ErrDefaultAddressKept
+	}
+	if makeDefault != nil && *makeDefault && !isDefault {
+		if err := clearDefaultAddress(tx, a.UserID); err != nil {
+			tx.Rollback()
+			return Address{}, err
+		}
+		isDefault = true
+	}
+	var updated Address
+	err = tx.Get(&updated, `
+		UPDATE addresses
+		SET label = $1, line1 = $2, line2 = $3, city = $4, postal_code = $5, phone = $6, latitude = $7,
+			longitude = $8, courier_notes = $9, is_default = $10, updated_at = now()
+		WHERE id = $11 AND user_id = $12
+		RETURNING `+addressColumns,
+		a.Label, a.Line1, a.Line2, a.City, a.PostalCode, a.Phone, a.Latitude,
+		a.Longitude, a.CourierNotes, isDefault, a.ID, a.UserID)
+	if err != nil {
+		tx.Rollback()
+		return updated, err
+	}
+	return updated, tx.Commit()
+}
+
+// SetDefaultAddress makes an address the user's default
+func SetDefaultAddress(userID, id int) (Address, error) {
+	tx, err := db.DB.Beginx()
+	if err != nil {
+		return Address{}, err
+	}
+	if err := lockUser(tx, userID); err != nil {
+		tx.Rollback()
+		return Address{}, err
+	}
+	if err := clearDefaultAddress(tx, userID); err != nil {
+		tx.Rollback()
+		return Address{}, err
+	}
+	var address Address
+	err = tx.Get(&address, `
+		UPDATE addresses SET is_default = true, updated_at = now()
+		WHERE id = $1 AND user_id = $2
+		RETURNING `+addressColumns, id, userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		tx.Rollback()
+		return address, ErrAddressNotFound
+	}
+	if err != nil {
+		tx.Rollback()
+		return address, err
+	}
+	return address, tx.Commit()
+}
+
+// DeleteAddress removes an address from a user's book. When it was the
+// default, the user's newest other address becomes the default.
+func DeleteAddress(userID, id int) error {
+	tx, err := db.DB.Beginx()
+	if err != nil {
+		return err
+	}
+	if err := lockUser(tx, userID); err != nil {
+		tx.Rollback()
+		return err
+	}
+	var wasDefault bool
+	err = tx.Get(&wasDefault, `DELETE FROM addresses WHERE id = $1 AND user_id = $2 RETURNING is_default`, id, userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		tx.Rollback()
+		return ErrAddressNotFound
+	}
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	if wasDefault {
+		_, err = tx.Exec(`
+			UPDATE addresses SET is_default = true
+			WHERE id = (SELECT id FROM addresses WHERE user_id = $1 ORDER BY id DESC LIMIT 1)
+		`, userID)
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// deliveryAddress returns the address a delivery order goes to: the given
+// one, or the user's default when addressID is 0
+func deliveryAddress(tx *sqlx.Tx, userID, addressID int) (Address, error) {
+	var address Address
+	var err error
+	if addressID == 0 {
+		err = tx.Get(&address, `SELECT `+addressColumns+` FROM addresses WHERE user_id = $1 AND is_default`, userID)
+		if errors.Is(err, sql.ErrNoRows) {
+			return address, ErrAddressRequired
+		}
+		return address, err
+	}
+	err = tx.Get(&address, `SELECT `+addressColumns+` FROM addresses WHERE id = $1 AND user_id = $2`, addressID, userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return address, ErrAddressNotFound
+	}
+	return address, err
+}
@@ -0,0 +1,230 @@
+package repository
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/Anwarjondev/fast-food/internal/db"
+	"github.com/Anwarjondev/fast-food/internal/money"
+)
+
+var (
+	ErrCartEmpty        = errors.New("cart is empty")
+	ErrCartItemNotFound = errors.New("food is not in the cart")
+	ErrCartItemLimit    = fmt.Errorf("cart can hold at most %d of one food", MaxItemCount)
+	ErrCartFull         = fmt.Errorf("cart can hold at most %d different foods", MaxOrderLines)
+)
+
+// CartItem is a food in a cart with its current name, price and stock.
+// Available is false when the food was deleted or there is not enough of it.
+type CartItem struct {
+	FoodID    int         `json:"food_id" db:"food_id"`
+	FoodName  string      `json:"food_name" db:"food_name"`
+	UnitPrice money.Money `json:"unit_price" db:"unit_price"`
+	Count     int         `json:"count" db:"count"`
+	LineTotal money.Money `json:"line_total" db:"line_total"`
+	InStock   int         `json:"in_stock" db:"in_stock"`
+	Available bool        `json:"available" db:"available"`
+}
+
+// Cart is a user's basket. Total is in the currency of the items, or in
+// currency when the cart is empty.
+type Cart struct {
+	Items []CartItem  `json:"items"`
+	Total money.Money `json:"total"`
+}
+
+// GetCart returns the user's cart priced at the current food prices. The
+// total only counts available items. It fails with ErrMixedCurrencies when
+// the cart holds foods priced in different currencies.
+func GetCart(userID int, currency string) (Cart, error) {
+	cart := Cart{Items: []CartItem{}, Total: money.Zero(currency)}
+	err := db.DB.Select(&cart.Items, `
+		SELECT c.food_id, COALESCE(f.name, '') AS food_name,
+			f.price AS "unit_price.amount", f.currency AS "unit_price.currency", c.count,
+			f.price * c.count AS "line_total.amount", f.currency AS "line_total.currency", f.count_food AS in_stock,
+			f.deleted_at IS NULL AND f.count_food >= c.count AS available
+		FROM cart_items c
+		JOIN food f ON f.id = c.food_id
+		WHERE c.user_id = $1
+		ORDER BY c.created_at, c.food_id
+	`, userID)
+	if err != nil {
+		return cart, err
+	}
+	if len(cart.Items) > 0 {
+		cart.Total = money.Zero(cart.Items[0].UnitPrice.Currency)
+	}
+	for _, item := range cart.Items {
+		if item.UnitPrice.Currency != cart.Total.Currency {
+			return cart, ErrMixedCurrencies
+		}
+		if item.Available {
+			cart.Total = cart.Total.Add(item.LineTotal)
+		}
+	}
+	return cart, nil
+}
+
+// AddCartItem adds count of a food to the cart, on top of what is already there
+func AddCartItem(userID, foodID, count int) error {
+	return saveCartItem(userID, foodID, count, true)
+}
+
+// SetCartItem sets how many of a food the cart holds
+func SetCartItem(userID, foodID, count int) error {
+	return saveCartItem(userID, foodID, count, false)
+}
+
+// saveCartItem puts count of a food into the cart, added to what the cart
+// holds of it already when add is set. The cart is held to the limits of an
+// order, so that it can always be checked out.
+func saveCartItem(userID, foodID, count int, add bool) error {
+	tx, err := db.DB.Beginx()
+	if err != nil {
+		return err
+	}
+	if err := lockUser(tx, userID); err != nil {
+		tx.Rollback()
+		return err
+	}
+	var cart struct {
+		Lines int `db:"lines"`
+		Have  int `db:"have"`
+	}
+	err = tx.Get(&cart, `
+		SELECT count(*) AS lines, COALESCE(max(count) FILTER (WHERE food_id = $2), 0) AS have
+		FROM cart_items WHERE user_id = $1
+	`, userID, foodID)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	if add {
+		count += cart.Have
+	}
+	if count > MaxItemCount {
+		tx.Rollback()
+		return ErrCartItemLimit
+	}
+	if cart.Have == 0 && cart.Lines >= MaxOrderLines {
+		tx.Rollback()
+		return ErrCartFull
+	}
+	res, err := tx.Exec(`
+		INSERT INTO cart_items (user_id, food_id, count)
+		SELECT $1, id, $3 FROM food WHERE id = $2 AND deleted_at IS NULL
+		ON CONFLICT (user_id, food_id)
+		DO UPDATE SET count = EXCLUDED.count, updated_at = now()
+	`, userID, foodID, count)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		tx.Rollback()
+		return ErrFoodNotFound
+	}
+	return tx.Commit()
+}
+
+func RemoveCartItem(userID, foodID int) error {
+	res, err := db.DB.Exec(`DELETE FROM cart_items WHERE user_id = $1 AND food_id = $2`, userID, foodID)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrCartItemNotFound
+	}
+	return nil
+}
+
+func ClearCart(userID int) error {
+	_, err := db.DB.Exec(`DELETE FROM cart_items WHERE user_id = $1`, userID)
+	return err
+}
+
+// RefillCart puts the foods of an order back into the user's cart, next to
+// what the cart holds already. Like saveCartItem it keeps to the limits of an
+// order: counts are capped at MaxItemCount, and foods the cart does not hold
+// yet are only added, in the order of the order's lines, while the cart has
+// fewer than MaxOrderLines.
+func RefillCart(userID, orderID int) error {
+	tx, err := db.DB.Beginx()
+	if err != nil {
+		return err
+	}
+	if err := lockUser(tx, userID); err != nil {
+		tx.Rollback()
+		return err
+	}
+	_, err = tx.Exec(`
+		WITH refill AS (
+			SELECT d.food_id, SUM(d.count) AS count, min(d.id) AS first,
+				EXISTS (SELECT 1 FROM cart_items c WHERE c.user_id = $1 AND c.food_id = d.food_id) AS in_cart
+			FROM order_detail d
+			WHERE d.order_id = $2
+			GROUP BY d.food_id
+		), added AS (
+			SELECT food_id FROM refill
+			WHERE NOT in_cart
+			ORDER BY first
+			LIMIT GREATEST($4 - (SELECT count(*) FROM cart_items WHERE user_id = $1), 0)
+		)
+		INSERT INTO cart_items (user_id, food_id, count)
+		SELECT $1, food_id, LEAST(count, $3) FROM refill
+		WHERE in_cart OR food_id IN (SELECT food_id FROM added)
+		ON CONFLICT (user_id, food_id)
+		DO UPDATE SET count = LEAST(cart_items.count + EXCLUDED.count, $3), updated_at = now()
+	`, userID, orderID, MaxItemCount, MaxOrderLines)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// CheckoutCart turns the user's cart into an order like CreateOrder and
+// empties it in the same transaction, so the cart is kept when ordering
+// fails. The items of input are replaced with the contents of the cart.
+func CheckoutCart(userID int, input NewOrder, charges Charges, times KitchenTimes) (Order, error) {
+	tx, err := db.DB.Beginx()
+	if err != nil {
+		return Order{}, err
+	}
+	input.Items = nil
+	err = tx.Select(&input.Items, `
+		SELECT food_id, count FROM cart_items
+		WHERE user_id = $1
+		ORDER BY created_at, food_id
+		FOR UPDATE
+	`, userID)
+	if err != nil {
+		tx.Rollback()
+		return Order{}, err
+	}
+	if len(input.Items) == 0 {
+		tx.Rollback()
+		return Order{}, ErrCartEmpty
+	}
+	if len(input.Items) > MaxOrderLines {
+		tx.Rollback()
+		return Order{}, ErrCartFull
+	}
+	for _, item := range input.Items {
+		if item.Count > MaxItemCount {
+			tx.Rollback()
+			return Order{}, ErrCartItemLimit
+		}
+	}
+	order, created, err := placeOrder(tx, userID, input, charges, times)
+	if err != nil {
+		tx.Rollback()
+		return order, err
+	}
+	if _, err := tx.Exec(`DELETE FROM cart_items WHERE user_id = $1`, userID); err != nil {
+		tx.Rollback()
+		return order, err
+	}
+	return order, commitOrderEvents(tx, created)
+}
@@ -1,20 +1,149 @@
 package repository
 
-import "github.com/Anwarjondev/fast-food/internal/db"
+import (
+	"database/sql"
+	"errors"
 
+	"github.com/Anwarjondev/fast-food/internal/db"
+	"github.com/lib/pq"
+)
+
+var (
+	ErrCategoryNotFound = errors.New("category not found")
+	ErrCategoryExists   = errors.New("category with this name already exists")
+	ErrCategoryNotEmpty = errors.New("category still has foods")
+)
+
+// Category is a menu section. TaxRate is the percent its foods are taxed
+// at; categories without one use the default rate.
 type Category struct {
-	ID   int    `json:"id"`
-	Name string `json:"name"`
+	ID        int      `json:"id" db:"id"`
+	Name      string   `json:"name" db:"name"`
+	SortOrder int      `json:"sort_order" db:"sort_order"`
+	TaxRate   *float64 `json:"tax_rate" db:"tax_rate"`
 }
 
 func GetAllCategories() ([]Category, error) {
 	var categories []Category
-	err := db.DB.Select(&categories, "SELECT id, name FROM category")
+	err := db.DB.Select(&categories, `
+		SELECT id, name, sort_order, tax_rate
+		FROM category
+		WHERE deleted_at IS NULL
+		ORDER BY sort_order, id
+	`)
 	return categories, err
 }
 
 func GetCategoryById(id int) (Category, error) {
 	var category Category
-	err := db.DB.Get(&category, "SELECT id, name FROM category WHERE id = $1", id)
+	err := db.DB.Get(&category, `SELECT id, name, sort_order, tax_rate FROM category WHERE id = $1 AND deleted_at IS NULL`, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return category, ErrCategoryNotFound
+	}
+	return category, err
+}
+
+// isUniqueViolation reports whether err is a violation of the unique index or
+// constraint named constraint
+func isUniqueViolation(err error, constraint string) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == "23505" && pqErr.Constraint == constraint
+}
+
+func categoryNameTaken(name string, exceptID int) (bool, error) {
+	var exists bool
+	err := db.DB.Get(&exists, `
+		SELECT EXISTS(
+			SELECT 1 FROM category
+			WHERE lower(name) = lower($1) AND id <> $2 AND deleted_at IS NULL
+		)
+	`, name, exceptID)
+	return exists, err
+}
+
+func CreateCategory(name string, taxRate *float64) (Category, error) {
+	taken, err := categoryNameTaken(name, 0)
+	if err != nil {
+		return Category{}, err
+	}
+	if taken {
+		return Category{}, ErrCategoryExists
+	}
+	var category Category
+	err = db.DB.Get(&category, `
+		INSERT INTO category (name, tax_rate, sort_order)
+		VALUES ($1, $2, (SELECT COALESCE(MAX(sort_order), 0) + 1 FROM category WHERE deleted_at IS NULL))
+		RETURNING id, name, sort_order, tax_rate
+	`, name, taxRate)
+	if isUniqueViolation(err, "category_name_key") {
+		return category, ErrCategoryExists
+	}
+	return category, err
+}
+
+func UpdateCategory(id int, name string, taxRate *float64) (Category, error) {
+	taken, err := categoryNameTaken(name, id)
+	if err != nil {
+		return Category{}, err
+	}
+	if taken {
+		return Category{}, ErrCategoryExists
+	}
+	var category Category
+	err = db.DB.Get(&category, `
+		UPDATE category SET name = $1, tax_rate = $2
+		WHERE id = $3 AND deleted_at IS NULL
+		RETURNING id, name, sort_order, tax_rate
+	`, name, taxRate, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return category, ErrCategoryNotFound
+	}
+	if isUniqueViolation(err, "category_name_key") {
+		return category, ErrCategoryExists
+	}
 	return category, err
 }
+
+// DeleteCategory soft-deletes a category. Categories that still contain
+// foods are kept so that the menu never shows orphaned items.
+func DeleteCategory(id int) error {
+	if _, err := GetCategoryById(id); err != nil {
+		return err
+	}
+	var hasFoods bool
+	err := db.DB.Get(&hasFoods, `SELECT EXISTS(SELECT 1 FROM food WHERE category_id = $1 AND deleted_at IS NULL)`, id)
+	if err != nil {
+		return err
+	}
+	if hasFoods {
+		return ErrCategoryNotEmpty
+	}
+	res, err := db.DB.Exec(`UPDATE category SET deleted_at = now() WHERE id = $1 AND deleted_at IS NULL`, id)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrCategoryNotFound
+	}
+	return nil
+}
+
+// ReorderCategories sets the display order of categories to the order of ids.
+func ReorderCategories(ids []int) error {
+	tx, err := db.DB.Beginx()
+	if err != nil {
+		return err
+	}
+	for i, id := range ids {
+		res, err := tx.Exec(`UPDATE category SET sort_order = $1 WHERE id = $2 AND deleted_at IS NULL`, i+1, id)
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+		if n, _ := res.RowsAffected(); n == 0 {
+			tx.Rollback()
+			return ErrCategoryNotFound
+		}
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,110 @@
+package repository
+
+import (
+	"crypto/subtle"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/Anwarjondev/fast-food/internal/db"
+	"github.com/Anwarjondev/fast-food/internal/utils"
+	"github.com/jmoiron/sqlx"
+)
+
+// Purposes of verification codes stored in the confirm table
+const (
+	CodePurposeSignup = "signup"
+	CodePurposeReset  = "reset"
+)
+
+var (
+	ErrCodeInvalid         = errors.New("invalid code")
+	ErrCodeExpired         = errors.New("code has expired, request a new one")
+	ErrCodeTooManyAttempts = errors.New("too many attempts, request a new code")
+)
+
+// hashCode binds the code to its user and purpose so the same digits issued
+// to two users never produce the same hash
+func hashCode(userID int, purpose, code string) string {
+	return utils.HashToken(fmt.Sprintf("%d:%s:%s", userID, purpose, code))
+}
+
+// SaveCode stores a new code for the user and purpose, invalidating any
+// earlier code for the same purpose
+func SaveCode(userID int, purpose, code string, ttl time.Duration) error {
+	tx, err := db.DB.Beginx()
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec(`Update confirm set is_passed = true where user_id = $1 and purpose = $2 and is_passed = false`, userID, purpose)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	_, err = tx.Exec(`
+		Insert into confirm(user_id, code_hash, purpose, expires_at, is_passed, created_at)
+		values($1, $2, $3, now() + make_interval(secs => $4), false, now())
+	`, userID, hashCode(userID, purpose, code), purpose, ttl.Seconds())
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// VerifyCode checks and consumes the latest code of the user for purpose.
+// Every wrong guess counts against maxAttempts.
+func VerifyCode(userID int, purpose, code string, maxAttempts int) error {
+	tx, err := db.DB.Beginx()
+	if err != nil {
+		return err
+	}
+	err = useCode(tx, userID, purpose, code, maxAttempts)
+	if err != nil && !errors.Is(err, ErrCodeInvalid) {
+		tx.Rollback()
+		return err
+	}
+	if cerr := tx.Commit(); cerr != nil {
+		return cerr
+	}
+	return err
+}
+
+// useCode checks the latest code of the user for purpose and marks it used
+// in tx. A wrong guess is counted in tx too and returns ErrCodeInvalid, so
+// callers commit tx on ErrCodeInvalid and roll it back on other errors.
+func useCode(tx *sqlx.Tx, userID int, purpose, code string, maxAttempts int) error {
+	var row struct {
+		ID       int    `db:"id"`
+		CodeHash string `db:"code_hash"`
+		Expired  bool   `db:"expired"`
+		Attempts int    `db:"attempts"`
+	}
+	err := tx.Get(&row, `
+		Select id, code_hash, expires_at <= now() AS expired, attempts from confirm
+		where user_id = $1 and purpose = $2 and is_passed = false
+		order by id desc limit 1
+		for update
+	`, userID, purpose)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrCodeInvalid
+	}
+	if err != nil {
+		return err
+	}
+	if row.Expired {
+		return ErrCodeExpired
+	}
+	if row.Attempts >= maxAttempts {
+		return ErrCodeTooManyAttempts
+	}
+	if subtle.ConstantTimeCompare([]byte(row.CodeHash), []byte(hashCode(userID, purpose, code))) != 1 {
+		if _, err := tx.Exec(`Update confirm set attempts = attempts + 1 where id = $1`, row.ID); err != nil {
+			return err
+		}
+		return ErrCodeInvalid
+	}
+	_, err = tx.Exec(`Update confirm set is_passed = true where id = $1`, row.ID)
+	return err
+}
@@ -0,0 +1,175 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/Anwarjondev/fast-food/internal/db"
+	"github.com/Anwarjondev/fast-food/internal/geo"
+	"github.com/Anwarjondev/fast-food/internal/money"
+	"github.com/jmoiron/sqlx"
+	"github.com/jmoiron/sqlx/types"
+	"github.com/lib/pq"
+)
+
+var (
+	ErrDeliveryZoneNotFound = errors.New("delivery zone not found")
+	ErrOutsideDeliveryArea  = errors.New("address is outside the delivery area")
+	ErrBelowZoneMinimum     = errors.New("order is below the minimum for delivery to this address")
+)
+
+// DeliveryZone is an area delivered to. Area is a GeoJSON Polygon or
+// MultiPolygon. Orders delivered into the zone must reach MinOrder before
+// discounts and pay DeliveryFee, and the courier needs TravelMinutes to get
+// there.
+type DeliveryZone struct {
+	ID            int            `json:"id" db:"id"`
+	Name          string         `json:"name" db:"name"`
+	Area          types.JSONText `json:"area" db:"area" swaggertype:"object"`
+	MinOrder      money.Money    `json:"min_order" db:"min_order"`
+	DeliveryFee   money.Money    `json:"delivery_fee" db:"delivery_fee"`
+	TravelMinutes int            `json:"travel_minutes" db:"travel_minutes"`
+	IsActive      bool           `json:"is_active" db:"is_active"`
+	CreatedAt     time.Time      `json:"created_at" db:"created_at"`
+	UpdatedAt     time.Time      `json:"updated_at" db:"updated_at"`
+}
+
+const deliveryZoneColumns = `id, name, area, min_order AS "min_order.amount", currency AS "min_order.currency",
+	delivery_fee AS "delivery_fee.amount", currency AS "delivery_fee.currency", travel_minutes, is_active,
+	created_at, updated_at`
+
+func GetDeliveryZones() ([]DeliveryZone, error) {
+	zones := []DeliveryZone{}
+	err := db.DB.Select(&zones, `SELECT `+deliveryZoneColumns+` FROM delivery_zones ORDER BY id`)
+	return zones, err
+}
+
+func GetDeliveryZone(id int) (DeliveryZone, error) {
+	var zone DeliveryZone
+	err := db.DB.Get(&zone, `SELECT `+deliveryZoneColumns+` FROM delivery_zones WHERE id = $1`, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return zone, ErrDeliveryZoneNotFound
+	}
+	return zone, err
+}
+
+// CreateDeliveryZone adds a zone. Its area must already have been checked
+// with geo.ParseShape.
+func CreateDeliveryZone(z DeliveryZone) (DeliveryZone, error) {
+	var created DeliveryZone
+	err := db.DB.Get(&created, `
+		INSERT INTO delivery_zones (name, area, min_order, delivery_fee, currency, travel_minutes, is_active)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING `+deliveryZoneColumns,
+		z.Name, z.Area, z.MinOrder.Amount, z.DeliveryFee.Amount, z.DeliveryFee.Currency, z.TravelMinutes, z.IsActive)
+	return created, err
+}
+
+// UpdateDeliveryZone changes a zone; orders already placed keep their fee
+func UpdateDeliveryZone(z DeliveryZone) (DeliveryZone, error) {
+	var updated DeliveryZone
+	err := db.DB.Get(&updated, `
+		UPDATE delivery_zones
+		SET name = $1, area = $2, min_order = $3, delivery_fee = $4, currency = $5, travel_minutes = $6,
+			is_active = $7, updated_at = now()
+		WHERE id = $8
+		RETURNING `+deliveryZoneColumns,
+		z.Name, z.Area, z.MinOrder.Amount, z.DeliveryFee.Amount, z.DeliveryFee.Currency, z.TravelMinutes,
+		z.IsActive, z.ID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return updated, ErrDeliveryZoneNotFound
+	}
+	return updated, err
+}
+
+func DeleteDeliveryZone(id int) error {
+	res, err := db.DB.Exec(`DELETE FROM delivery_zones WHERE id = $1`, id)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrDeliveryZoneNotFound
+	}
+	return nil
+}
+
+// findDeliveryZone returns the active zone that contains p, the oldest one
+// when zones overlap. zoned is false when there are no active zones at all,
+// in which case every address can be delivered to.
+func findDeliveryZone(tx *sqlx.Tx, p geo.Point) (zone DeliveryZone, found, zoned bool, err error) {
+	var zones []DeliveryZone
+	err = tx.Select(&zones, `SELECT `+deliveryZoneColumns+` FROM delivery_zones WHERE is_active ORDER BY id`)
+	if err != nil {
+		return zone, false, false, err
+	}
+	for _, z := range zones {
+		shape, err := geo.ParseShape(z.Area)
+		if err != nil {
+			return zone, false, true, err
+		}
+		if shape.Contains(p) {
+			return z, true, true, nil
+		}
+	}
+	return zone, false, len(zones) > 0, nil
+}
+
+// cookingStatuses are the statuses of orders the kitchen still has to make
+var cookingStatuses = []string{StatusPaid, StatusPlaced, StatusAccepted, StatusPreparing}
+
+// KitchenTimes estimate when orders are done: PrepTime for the order itself,
+// QueueTime for every order the kitchen has to make first, and TravelTime
+// for delivery orders outside any zone.
+type KitchenTimes struct {
+	PrepTime   time.Duration
+	QueueTime  time.Duration
+	TravelTime time.Duration
+}
+
+// estimateOrder records when an order should reach the customer, or be
+// ready for pickup, from the orders the kitchen has to make before it and the
+// travel time of its delivery zone, and returns the order afterwards
+func estimateOrder(q sqlx.Queryer, orderID int, times KitchenTimes) (Order, error) {
+	var trip struct {
+		Fulfillment   string `db:"fulfillment"`
+		TravelMinutes *int   `db:"travel_minutes"`
+	}
+	err := sqlx.Get(q, &trip, `
+		SELECT o.fulfillment, z.travel_minutes
+		FROM orders o
+		LEFT JOIN delivery_zones z ON z.id = o.delivery_zone_id
+		WHERE o.id = $1
+	`, orderID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return Order{}, ErrOrderNotFound
+	}
+	if err != nil {
+		return Order{}, err
+	}
+	var queue int
+	err = sqlx.Get(q, &queue, `SELECT count(*) FROM orders WHERE status = ANY($1) AND id < $2`,
+		pq.Array(cookingStatuses), orderID)
+	if err != nil {
+		return Order{}, err
+	}
+	wait := times.PrepTime + time.Duration(queue)*times.QueueTime
+	if trip.Fulfillment == FulfillmentDelivery {
+		if trip.TravelMinutes != nil {
+			wait += time.Duration(*trip.TravelMinutes) * time.Minute
+		} else {
+			wait += times.TravelTime
+		}
+	}
+	var order Order
+	err = sqlx.Get(q, &order, `
+		UPDATE orders SET estimated_at = now() + make_interval(secs => $1) WHERE id = $2
+		RETURNING `+orderColumns, wait.Seconds(), orderID)
+	return order, err
+}
+
+// EstimateOrder estimates an order again from now. Orders are estimated when
+// they are placed; this is for orders whose payment was only decided later.
+func EstimateOrder(orderID int, times KitchenTimes) (Order, error) {
+	return estimateOrder(db.DB, orderID, times)
+}
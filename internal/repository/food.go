@@ -1,22 +1,150 @@
 package repository
 
-import "github.com/Anwarjondev/fast-food/internal/db"
+import (
+	"database/sql"
+	"errors"
+
+	"github.com/Anwarjondev/fast-food/internal/db"
+	"github.com/Anwarjondev/fast-food/internal/money"
+)
+
+var (
+	ErrFoodNotFound = errors.New("food not found")
+	ErrFoodExists   = errors.New("food with this name already exists in the category")
+)
 
 type Food struct {
-	ID         int     `json:"id" db:"id"`
-	Name       string  `json:"name" db:"name"`
-	Price      float64 `json:"price" db:"price"`
-	CategoryID int     `json:"category_id" db:"category_id"`
-	ImageURL   string  `json:"img_url" db:"img_url"`
-	CountFood  int     `json:"count_food" db:"count_food"`
+	ID         int         `json:"id" db:"id"`
+	Name       string      `json:"name" db:"name"`
+	Price      money.Money `json:"price" db:"price"`
+	CategoryID int         `json:"category_id" db:"category_id"`
+	ImageURL   string      `json:"img_url" db:"img_url"`
+	CountFood  int         `json:"count_food" db:"count_food"`
+	SortOrder  int         `json:"sort_order" db:"sort_order"`
 }
 
+// foodColumns lists the food columns scanned into Food
+const foodColumns = `id, name, price AS "price.amount", currency AS "price.currency", category_id,
+	COALESCE(img_url, '') AS img_url, COALESCE(count_food, 0) AS count_food, sort_order`
+
 func GetFoodsByCategory(categoryID int) ([]Food, error) {
 	var foods []Food
 	err := db.DB.Select(&foods, `
-		SELECT name, price, category_id, img_url, count_food
-		FROM food 
-		WHERE category_id = $1
+		SELECT `+foodColumns+`
+		FROM food
+		WHERE category_id = $1 AND deleted_at IS NULL
+		ORDER BY sort_order, id
 	`, categoryID)
 	return foods, err
 }
+
+func GetFoodByID(id int) (Food, error) {
+	var food Food
+	err := db.DB.Get(&food, `
+		SELECT `+foodColumns+`
+		FROM food
+		WHERE id = $1 AND deleted_at IS NULL
+	`, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return food, ErrFoodNotFound
+	}
+	return food, err
+}
+
+func checkFood(food Food) error {
+	if _, err := GetCategoryById(food.CategoryID); err != nil {
+		return err
+	}
+	var exists bool
+	err := db.DB.Get(&exists, `
+		SELECT EXISTS(
+			SELECT 1 FROM food
+			WHERE category_id = $1 AND lower(name) = lower($2) AND id <> $3 AND deleted_at IS NULL
+		)
+	`, food.CategoryID, food.Name, food.ID)
+	if err != nil {
+		return err
+	}
+	if exists {
+		return ErrFoodExists
+	}
+	return nil
+}
+
+func CreateFood(food Food) (Food, error) {
+	if err := checkFood(food); err != nil {
+		return Food{}, err
+	}
+	var created Food
+	err := db.DB.Get(&created, `
+		INSERT INTO food (name, price, currency, category_id, img_url, count_food, sort_order)
+		VALUES ($1, $2, $3, $4, $5, $6,
+			(SELECT COALESCE(MAX(sort_order), 0) + 1 FROM food WHERE category_id = $4 AND deleted_at IS NULL))
+		RETURNING `+foodColumns,
+		food.Name, food.Price.Amount, food.Price.Currency, food.CategoryID, food.ImageURL, food.CountFood)
+	if isUniqueViolation(err, "food_name_key") {
+		return created, ErrFoodExists
+	}
+	return created, err
+}
+
+func UpdateFood(food Food) (Food, error) {
+	if _, err := GetFoodByID(food.ID); err != nil {
+		return Food{}, err
+	}
+	if err := checkFood(food); err != nil {
+		return Food{}, err
+	}
+	var updated Food
+	err := db.DB.Get(&updated, `
+		UPDATE food
+		SET name = $1, price = $2, currency = $3, category_id = $4, img_url = $5, count_food = $6
+		WHERE id = $7 AND deleted_at IS NULL
+		RETURNING `+foodColumns,
+		food.Name, food.Price.Amount, food.Price.Currency, food.CategoryID, food.ImageURL, food.CountFood, food.ID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return updated, ErrFoodNotFound
+	}
+	if isUniqueViolation(err, "food_name_key") {
+		return updated, ErrFoodExists
+	}
+	return updated, err
+}
+
+// DeleteFood soft-deletes a food so existing order details keep their reference.
+func DeleteFood(id int) error {
+	res, err := db.DB.Exec(`UPDATE food SET deleted_at = now() WHERE id = $1 AND deleted_at IS NULL`, id)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrFoodNotFound
+	}
+	return nil
+}
+
+// ReorderFoods sets the display order of the foods of a category to the order of ids.
+func ReorderFoods(categoryID int, ids []int) error {
+	if _, err := GetCategoryById(categoryID); err != nil {
+		return err
+	}
+	tx, err := db.DB.Beginx()
+	if err != nil {
+		return err
+	}
+	for i, id := range ids {
+		res, err := tx.Exec(`
+			UPDATE food SET sort_order = $1
+			WHERE id = $2 AND category_id = $3 AND deleted_at IS NULL
+		`, i+1, id, categoryID)
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+		if n, _ := res.RowsAffected(); n == 0 {
+			tx.Rollback()
+			return ErrFoodNotFound
+		}
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,93 @@
+package repository
+
+import (
+	"github.com/Anwarjondev/fast-food/internal/db"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+// KitchenStatuses are the statuses of orders the kitchen still has to work
+// on. Orders waiting for payment are not shown to the kitchen.
+var KitchenStatuses = []string{StatusPaid, StatusPlaced, StatusAccepted, StatusPreparing, StatusReady}
+
+// GetKitchenQueue returns the orders in any of statuses, oldest first, with
+// their lines
+func GetKitchenQueue(statuses []string) ([]OrderWithLines, error) {
+	var orders []Order
+	err := db.DB.Select(&orders, `
+		select `+orderColumns+` from orders
+		where status = ANY($1)
+		order by created_at, id
+	`, pq.Array(statuses))
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]int64, len(orders))
+	for i, order := range orders {
+		ids[i] = int64(order.ID)
+	}
+	lines, err := getLinesByOrder(db.DB, ids)
+	if err != nil {
+		return nil, err
+	}
+	queue := make([]OrderWithLines, len(orders))
+	for i, order := range orders {
+		queue[i] = OrderWithLines{Order: order, Lines: lines[order.ID]}
+		if queue[i].Lines == nil {
+			queue[i].Lines = []OrderLine{}
+		}
+	}
+	return queue, nil
+}
+
+// getLinesByOrder loads the lines of several orders at once, keyed by order ID
+func getLinesByOrder(q sqlx.Queryer, orderIDs []int64) (map[int][]OrderLine, error) {
+	var rows []struct {
+		OrderID int `db:"order_id"`
+		OrderLine
+	}
+	err := sqlx.Select(q, &rows, `
+		SELECT d.order_id, `+orderLineColumns+`
+		FROM order_detail d
+		JOIN orders o ON o.id = d.order_id
+		WHERE d.order_id = ANY($1)
+		ORDER BY d.order_id, d.id
+	`, pq.Array(orderIDs))
+	if err != nil {
+		return nil, err
+	}
+	lines := map[int][]OrderLine{}
+	for _, row := range rows {
+		lines[row.OrderID] = append(lines[row.OrderID], row.OrderLine)
+	}
+	return lines, nil
+}
+
+// RejectOrder moves an order to rejected on behalf of staff, puts its foods
+// back into stock and refunds refundAmount of its payment, or all of it when
+// refundAmount is nil
+func RejectOrder(orderID, actorID int, reason string, refundAmount *int64) (Order, error) {
+	tx, err := db.DB.Beginx()
+	if err != nil {
+		return Order{}, err
+	}
+	order, err := lockOrder(tx, orderID)
+	if err != nil {
+		tx.Rollback()
+		return order, err
+	}
+	e, err := transitionLocked(tx, &order, StatusRejected, actorID, reason)
+	if err != nil {
+		tx.Rollback()
+		return order, err
+	}
+	if err := restoreStock(tx, orderID); err != nil {
+		tx.Rollback()
+		return order, err
+	}
+	if err := queueRefund(tx, orderID, refundAmount, actorID, reason); err != nil {
+		tx.Rollback()
+		return order, err
+	}
+	return order, commitOrderEvents(tx, e)
+}
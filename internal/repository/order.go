@@ -1,67 +1,483 @@
 package repository
 
 import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"sort"
 	"time"
 
 	"github.com/Anwarjondev/fast-food/internal/db"
+	"github.com/Anwarjondev/fast-food/internal/events"
+	"github.com/Anwarjondev/fast-food/internal/money"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
 )
 
+var (
+	ErrOrderNotFound       = errors.New("order not found")
+	ErrMixedCurrencies     = errors.New("foods priced in different currencies cannot be ordered together")
+	ErrOrderForbidden      = errors.New("order belongs to another user")
+	ErrOrderNotCancelable  = errors.New("order can no longer be canceled")
+	ErrCancelWindowExpired = errors.New("time to cancel the order has passed")
+)
+
+// Order is an order's header. TotalPrice is Subtotal less Discount plus
+// ServiceCharge, PackagingFee and DeliveryFee, plus Tax unless TaxInclusive
+// is set, in which case Tax is already part of the prices. Delivery orders
+// carry a copy of their address in DeliveryAddress; AddressID is the address
+// book entry it came from, while it exists, and DeliveryZoneID the zone it
+// lies in. EstimatedAt is when the order should reach the customer, or be
+// ready for pickup.
 type Order struct {
-	ID          int       `json:"id" db:"id"`
-	UserID      int       `json:"user_id" db:"user_id"`
-	Status      string    `json:"status" db:"status"`
-	CreatedAt   time.Time `json:"created_at" db:"created_at"`
-	DeliveredAt time.Time `json:"delivered_at" db:"delivered_at"`
-	TotalPrice  float64   `json:"total_price" db:"total_amount"`
+	ID            int         `json:"id" db:"id"`
+	UserID        int         `json:"user_id" db:"user_id"`
+	Status        string      `json:"status" db:"status"`
+	CreatedAt     time.Time   `json:"created_at" db:"created_at"`
+	DeliveredAt   *time.Time  `json:"delivered_at" db:"delivered_at"`
+	Subtotal      money.Money `json:"subtotal" db:"subtotal"`
+	Discount      money.Money `json:"discount" db:"discount"`
+	PromoCode     *string     `json:"promo_code" db:"promo_code"`
+	ServiceCharge money.Money `json:"service_charge" db:"service_charge"`
+	PackagingFee  money.Money `json:"packaging_fee" db:"packaging_fee"`
+	Tax           money.Money `json:"tax" db:"tax"`
+	TaxInclusive  bool        `json:"tax_inclusive" db:"tax_inclusive"`
+	TotalPrice    money.Money `json:"total_price" db:"total"`
+
+	Fulfillment     string        `json:"fulfillment" db:"fulfillment"`
+	AddressID       *int          `json:"address_id" db:"address_id"`
+	DeliveryAddress *OrderAddress `json:"delivery_address" db:"delivery_address"`
+	DeliveryFee     money.Money   `json:"delivery_fee" db:"delivery_fee"`
+	DeliveryZoneID  *int          `json:"delivery_zone_id" db:"delivery_zone_id"`
+	EstimatedAt     *time.Time    `json:"estimated_at" db:"estimated_at"`
 }
+
+// MaxItemCount is the most of one food an order can hold, and MaxOrderLines
+// the most different foods
+const (
+	MaxItemCount  = 100
+	MaxOrderLines = 50
+)
+
 type OrderDetail struct {
-	FoodID int `json:"food_id"`
-	Count  int `json:"count"`
+	FoodID int `json:"food_id" db:"food_id"`
+	Count  int `json:"count" db:"count"`
 }
 
-func CreateOrder(UserID int, fooditems []OrderDetail) (int, error) {
-	var orderID int
-	var totalOrderPrice float64
-	tx := db.DB.MustBegin()
-	err := tx.QueryRow(`Insert into orders(user_id, total_amount, created_at, status) values($1, 0, now(), 'active') returning id`, UserID).Scan(&orderID)
+// OrderLine is an order_detail row with the food name and unit price as
+// they were when the order was placed
+type OrderLine struct {
+	FoodID    int         `json:"food_id" db:"food_id"`
+	FoodName  string      `json:"food_name" db:"food_name"`
+	UnitPrice money.Money `json:"unit_price" db:"unit_price"`
+	Count     int         `json:"count" db:"count"`
+	LineTotal money.Money `json:"line_total" db:"line_total"`
+	TaxRate   float64     `json:"tax_rate" db:"tax_rate"`
+}
+
+// OrderWithLines is an order with its lines and, on order detail, its tax
+// per rate, its payment and its refunds
+type OrderWithLines struct {
+	Order
+	Lines   []OrderLine `json:"lines"`
+	Taxes   []OrderTax  `json:"taxes,omitempty"`
+	Payment *Payment    `json:"payment,omitempty"`
+	Refunds []Refund    `json:"refunds,omitempty"`
+}
+
+// orderColumns lists the orders columns scanned into Order. Every amount of
+// an order is in the order's currency.
+const orderColumns = `id, user_id, status, created_at, delivered_at,
+	subtotal AS "subtotal.amount", currency AS "subtotal.currency",
+	discount_amount AS "discount.amount", currency AS "discount.currency", promo_code,
+	service_charge AS "service_charge.amount", currency AS "service_charge.currency",
+	packaging_fee AS "packaging_fee.amount", currency AS "packaging_fee.currency",
+	tax_amount AS "tax.amount", currency AS "tax.currency", tax_inclusive,
+	total_amount AS "total.amount", currency AS "total.currency",
+	fulfillment, address_id, delivery_address, delivery_fee AS "delivery_fee.amount", currency AS "delivery_fee.currency",
+	delivery_zone_id, estimated_at`
+
+// orderLineColumns lists the columns of order_detail d joined with orders o
+// scanned into OrderLine
+const orderLineColumns = `d.food_id, d.food_name,
+	d.unit_price AS "unit_price.amount", o.currency AS "unit_price.currency", d.count,
+	d.unit_price * d.count AS "line_total.amount", o.currency AS "line_total.currency", d.tax_rate`
+
+// StockShortage describes one order line that cannot be served from stock
+type StockShortage struct {
+	FoodID    int `json:"food_id"`
+	Requested int `json:"requested"`
+	Available int `json:"available"`
+}
+
+// InsufficientStockError is returned by CreateOrder when some foods do not
+// have enough stock; nothing is ordered in that case
+type InsufficientStockError struct {
+	Items []StockShortage
+}
+
+func (e *InsufficientStockError) Error() string {
+	return fmt.Sprintf("insufficient stock for %d item(s)", len(e.Items))
+}
+
+// UnknownFoodError is returned by CreateOrder when some foods do not exist
+// or were deleted
+type UnknownFoodError struct {
+	FoodIDs []int
+}
+
+func (e *UnknownFoodError) Error() string {
+	return fmt.Sprintf("unknown food(s): %v", e.FoodIDs)
+}
+
+func (e *UnknownFoodError) Unwrap() error {
+	return ErrFoodNotFound
+}
+
+// NewOrder is what a customer orders. PromoCode is optional and
+// PaymentMethod names the payment provider the order is paid with.
+// Fulfillment is delivery or pickup, pickup when empty; delivery orders go to
+// AddressID, or to the user's default address when it is 0.
+type NewOrder struct {
+	Items         []OrderDetail
+	PromoCode     string
+	PaymentMethod string
+	Fulfillment   string
+	AddressID     int
+}
+
+// CreateOrder places an order with taxes and fees added by charges. The order
+// waits in payment_pending, with a pending payment for its total, until
+// ResolvePayment records what the payment provider decided.
+func CreateOrder(UserID int, input NewOrder, charges Charges, times KitchenTimes) (Order, error) {
+	tx, err := db.DB.Beginx()
+	if err != nil {
+		return Order{}, err
+	}
+	order, created, err := placeOrder(tx, UserID, input, charges, times)
 	if err != nil {
 		tx.Rollback()
-		return 0, err
+		return order, err
 	}
+	return order, commitOrderEvents(tx, created)
+}
+
+// placeOrder creates an order in tx: it locks the foods, checks and
+// decrements their stock, snapshots names, prices and tax rates onto the
+// lines, applies the promo code when there is one, adds charges, starts the
+// payment and estimates when the order is done. It returns the event to
+// publish once tx commits; on error the caller must roll tx back.
+func placeOrder(tx *sqlx.Tx, UserID int, input NewOrder, charges Charges, times KitchenTimes) (Order, events.OrderEvent, error) {
+	fooditems := input.Items
+	// Total requested count per food, in id order so that concurrent orders
+	// lock food rows in the same order
+	requested := map[int]int{}
 	for _, item := range fooditems {
-		var price float64
-		err = tx.Get(&price, `SELECT price FROM food WHERE id = $1`, item.FoodID)
+		requested[item.FoodID] += item.Count
+	}
+	foodIDs := make([]int64, 0, len(requested))
+	for id := range requested {
+		foodIDs = append(foodIDs, int64(id))
+	}
+	sort.Slice(foodIDs, func(i, j int) bool { return foodIDs[i] < foodIDs[j] })
+
+	var foods []struct {
+		ID         int         `db:"id"`
+		Name       string      `db:"name"`
+		Price      money.Money `db:"price"`
+		CategoryID int         `db:"category_id"`
+		CountFood  int         `db:"count_food"`
+		TaxRate    float64     `db:"tax_rate"`
+	}
+	err := tx.Select(&foods, `
+		SELECT f.id, COALESCE(f.name, '') AS name, f.price AS "price.amount", f.currency AS "price.currency",
+			COALESCE(f.category_id, 0) AS category_id, f.count_food, COALESCE(c.tax_rate, $2) AS tax_rate
+		FROM food f
+		LEFT JOIN category c ON c.id = f.category_id
+		WHERE f.id = ANY($1) AND f.deleted_at IS NULL
+		ORDER BY f.id
+		FOR UPDATE OF f
+	`, pq.Array(foodIDs), charges.TaxRate)
+	if err != nil {
+		return Order{}, events.OrderEvent{}, err
+	}
+	foodByID := map[int]int{}
+	for i, food := range foods {
+		foodByID[food.ID] = i
+	}
+	if len(foods) != len(foodIDs) {
+		unknown := &UnknownFoodError{}
+		for _, id := range foodIDs {
+			if _, ok := foodByID[int(id)]; !ok {
+				unknown.FoodIDs = append(unknown.FoodIDs, int(id))
+			}
+		}
+		return Order{}, events.OrderEvent{}, unknown
+	}
+	var shortages []StockShortage
+	for _, food := range foods {
+		if requested[food.ID] > food.CountFood {
+			shortages = append(shortages, StockShortage{FoodID: food.ID, Requested: requested[food.ID], Available: food.CountFood})
+		}
+	}
+	if len(shortages) > 0 {
+		return Order{}, events.OrderEvent{}, &InsufficientStockError{Items: shortages}
+	}
+
+	// An order is paid in one currency, so the menu must be priced in one
+	currency := foods[0].Price.Currency
+	for _, food := range foods {
+		if food.Price.Currency != currency {
+			return Order{}, events.OrderEvent{}, ErrMixedCurrencies
+		}
+	}
+	lines := make([]pricedLine, len(fooditems))
+	for i, item := range fooditems {
+		food := foods[foodByID[item.FoodID]]
+		lines[i] = pricedLine{FoodID: food.ID, CategoryID: food.CategoryID, UnitPrice: food.Price, Count: item.Count, TaxRate: food.TaxRate}
+	}
+	var promotion Promotion
+	discount := money.Zero(currency)
+	discounted := func(pricedLine) bool { return false }
+	var appliedCode *string
+	if input.PromoCode != "" {
+		promotion, discount, err = redeemPromotion(tx, UserID, input.PromoCode, lines)
 		if err != nil {
-			tx.Rollback()
-			return 0, err
+			return Order{}, events.OrderEvent{}, err
 		}
-		itemTotal := price * float64(item.Count)
-		totalOrderPrice += itemTotal
-		_, err = tx.Exec(`Insert into order_detail (order_id, food_id, count) values($1, $2, $3)`, orderID, item.FoodID, item.Count)
+		appliedCode = &promotion.Code
+		discounted = promotion.appliesTo
+	}
+
+	fulfillment := input.Fulfillment
+	if fulfillment == "" {
+		fulfillment = FulfillmentPickup
+	}
+	deliveryFee := money.Zero(currency)
+	var addressID, zoneID *int
+	var snapshot *OrderAddress
+	var minOrder money.Money
+	switch fulfillment {
+	case FulfillmentDelivery:
+		address, err := deliveryAddress(tx, UserID, input.AddressID)
 		if err != nil {
-			tx.Rollback()
-			return 0, err
+			return Order{}, events.OrderEvent{}, err
+		}
+		// Zones decide where we deliver once there are any; until then the
+		// fee goes by distance
+		zone, found, zoned, err := findDeliveryZone(tx, address.point())
+		switch {
+		case err != nil:
+			return Order{}, events.OrderEvent{}, err
+		case zoned && !found:
+			return Order{}, events.OrderEvent{}, ErrOutsideDeliveryArea
+		case found:
+			if zone.DeliveryFee.Currency != currency {
+				return Order{}, events.OrderEvent{}, ErrMixedCurrencies
+			}
+			deliveryFee, minOrder, zoneID = zone.DeliveryFee, zone.MinOrder, &zone.ID
+		default:
+			fee, err := charges.Delivery.fee(address.point())
+			if err != nil {
+				return Order{}, events.OrderEvent{}, err
+			}
+			deliveryFee = money.New(fee, currency)
 		}
+		copied := address.snapshot()
+		addressID, snapshot = &address.ID, &copied
+	case FulfillmentPickup:
+	default:
+		return Order{}, events.OrderEvent{}, ErrInvalidFulfillment
 	}
-	_, err = tx.Exec(`UPDATE orders SET total_amount = $1 WHERE id = $2`, totalOrderPrice, orderID)
+	totals := charges.totals(lines, discount, discounted, deliveryFee)
+	if zoneID != nil && totals.Subtotal.LessThan(minOrder) {
+		return Order{}, events.OrderEvent{}, ErrBelowZoneMinimum
+	}
+
+	var orderID int
+	err = tx.QueryRow(`
+		Insert into orders(user_id, currency, subtotal, discount_amount, promo_code, service_charge, packaging_fee,
+			tax_amount, tax_inclusive, total_amount, created_at, status, fulfillment, address_id, delivery_address,
+			delivery_fee, delivery_zone_id)
+		values($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, now(), $11, $12, $13, $14, $15, $16) returning id
+	`, UserID, currency, totals.Subtotal.Amount, totals.Discount.Amount, appliedCode, totals.ServiceCharge.Amount,
+		totals.PackagingFee.Amount, totals.Tax.Amount, charges.TaxInclusive, totals.Total.Amount, StatusPaymentPending,
+		fulfillment, addressID, snapshot, totals.DeliveryFee.Amount, zoneID).Scan(&orderID)
 	if err != nil {
-		tx.Rollback()
-		return 0, err
+		return Order{}, events.OrderEvent{}, err
+	}
+	if err := insertOrderTaxes(tx, orderID, totals.Taxes); err != nil {
+		return Order{}, events.OrderEvent{}, err
 	}
-	err = tx.Commit()
-	return orderID, err
+	created, err := recordStatus(tx, orderID, UserID, nil, StatusPaymentPending, UserID, "")
+	if err != nil {
+		return Order{}, events.OrderEvent{}, err
+	}
+	if appliedCode != nil {
+		_, err = tx.Exec(`
+			INSERT INTO promotion_redemptions (promotion_id, order_id, user_id, discount)
+			VALUES ($1, $2, $3, $4)
+		`, promotion.ID, orderID, UserID, discount.Amount)
+		if err != nil {
+			return Order{}, events.OrderEvent{}, err
+		}
+	}
+	for _, item := range fooditems {
+		food := foods[foodByID[item.FoodID]]
+		_, err = tx.Exec(`
+			Insert into order_detail (order_id, food_id, count, food_name, unit_price, tax_rate)
+			values($1, $2, $3, $4, $5, $6)
+		`, orderID, item.FoodID, item.Count, food.Name, food.Price.Amount, food.TaxRate)
+		if err != nil {
+			return Order{}, events.OrderEvent{}, err
+		}
+	}
+	for _, id := range foodIDs {
+		_, err = tx.Exec(`UPDATE food SET count_food = count_food - $1 WHERE id = $2`, requested[int(id)], id)
+		if err != nil {
+			return Order{}, events.OrderEvent{}, err
+		}
+	}
+	if err := insertPayment(tx, orderID, input.PaymentMethod, totals.Total); err != nil {
+		return Order{}, events.OrderEvent{}, err
+	}
+	order, err := estimateOrder(tx, orderID, times)
+	return order, created, err
 }
+
+// GetAllOrderByStatus lists the user's orders. Besides a single status,
+// status can be "active" for every unfinished order, "completed" for
+// delivered orders or "all".
 func GetAllOrderByStatus(UserID int, status string) ([]Order, error) {
 	var orders []Order
 	var err error
-	if status == "all" {
-		err = db.DB.Select(&orders, `select *from orders where user_id = $1`, UserID)
-	} else {
-		err = db.DB.Select(&orders, `select *from orders where user_id = $1 and status = $2`, UserID, status)
+	switch status {
+	case "all":
+		err = db.DB.Select(&orders, `select `+orderColumns+` from orders where user_id = $1 order by created_at desc`, UserID)
+	case "active":
+		err = db.DB.Select(&orders, `select `+orderColumns+` from orders where user_id = $1 and status = ANY($2) order by created_at desc`, UserID, pq.Array(ActiveStatuses))
+	case "completed":
+		status = StatusDelivered
+		fallthrough
+	default:
+		err = db.DB.Select(&orders, `select `+orderColumns+` from orders where user_id = $1 and status = $2 order by created_at desc`, UserID, status)
 	}
 	return orders, err
 }
-func CancelOrder(UserID, OrderID int) error {
-	_, err := db.DB.Exec(`update orders set status = 'canceled' where id = $1 and user_id = $2 and status = 'active' and now() - created_at < interval '10 minutes'`, OrderID, UserID)
+
+func getOrderLines(q sqlx.Queryer, orderID int) ([]OrderLine, error) {
+	lines := []OrderLine{}
+	err := sqlx.Select(q, &lines, `
+		SELECT `+orderLineColumns+`
+		FROM order_detail d
+		JOIN orders o ON o.id = d.order_id
+		WHERE d.order_id = $1
+		ORDER BY d.id
+	`, orderID)
+	return lines, err
+}
+
+// GetOrder returns an order with its lines, taxes, payment and refunds
+func GetOrder(orderID int) (OrderWithLines, error) {
+	var order OrderWithLines
+	err := db.DB.Get(&order.Order, `select `+orderColumns+` from orders where id = $1`, orderID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return order, ErrOrderNotFound
+	}
+	if err != nil {
+		return order, err
+	}
+	order.Lines, err = getOrderLines(db.DB, orderID)
+	if err != nil {
+		return order, err
+	}
+	order.Taxes, err = getOrderTaxes(orderID)
+	if err != nil {
+		return order, err
+	}
+	payment, err := GetOrderPayment(orderID)
+	if errors.Is(err, ErrPaymentNotFound) {
+		// Placed before payments were recorded
+		return order, nil
+	}
+	if err != nil {
+		return order, err
+	}
+	order.Payment = &payment
+	order.Refunds, err = GetOrderRefunds(orderID)
+	return order, err
+}
+
+// restoreStock puts the foods of an order back into stock
+func restoreStock(tx *sqlx.Tx, orderID int) error {
+	_, err := tx.Exec(`
+		UPDATE food f SET count_food = f.count_food + d.total
+		FROM (SELECT food_id, SUM(count) AS total FROM order_detail WHERE order_id = $1 GROUP BY food_id) d
+		WHERE f.id = d.food_id
+	`, orderID)
 	return err
 }
+
+// CancelWindows are the statuses in which customers may cancel their orders,
+// each with how long after placing the order that is allowed. A zero window
+// has no limit; customers cannot cancel orders in statuses that are missing.
+type CancelWindows map[string]time.Duration
+
+// CancelOrder cancels an order on behalf of its owner, puts its foods back
+// into stock and refunds its payment. It fails with ErrOrderForbidden for
+// someone else's order, ErrOrderNotCancelable when windows does not allow
+// canceling in the order's status and ErrCancelWindowExpired when the window
+// for it has passed.
+func CancelOrder(UserID, OrderID int, reason string, windows CancelWindows) (Order, error) {
+	tx, err := db.DB.Beginx()
+	if err != nil {
+		return Order{}, err
+	}
+	order, err := lockOrder(tx, OrderID)
+	if err != nil {
+		tx.Rollback()
+		return order, err
+	}
+	if order.UserID != UserID {
+		tx.Rollback()
+		return order, ErrOrderForbidden
+	}
+	window, ok := windows[order.Status]
+	if !ok || !CanTransition(order.Status, StatusCanceled) {
+		tx.Rollback()
+		return order, ErrOrderNotCancelable
+	}
+	if window > 0 {
+		// Measured by the database clock, which set created_at
+		var expired bool
+		err = tx.Get(&expired, `SELECT now() - created_at >= make_interval(secs => $1) FROM orders WHERE id = $2`,
+			window.Seconds(), OrderID)
+		if err != nil {
+			tx.Rollback()
+			return order, err
+		}
+		if expired {
+			tx.Rollback()
+			return order, ErrCancelWindowExpired
+		}
+	}
+	if reason == "" {
+		reason = "canceled by customer"
+	} else {
+		reason = "canceled by customer: " + reason
+	}
+	e, err := transitionLocked(tx, &order, StatusCanceled, UserID, reason)
+	if err != nil {
+		tx.Rollback()
+		return order, err
+	}
+	if err := restoreStock(tx, OrderID); err != nil {
+		tx.Rollback()
+		return order, err
+	}
+	if err := queueRefund(tx, OrderID, nil, UserID, reason); err != nil {
+		tx.Rollback()
+		return order, err
+	}
+	return order, commitOrderEvents(tx, e)
+}
@@ -0,0 +1,351 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/Anwarjondev/fast-food/internal/db"
+	"github.com/Anwarjondev/fast-food/internal/events"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+// Order statuses. An order starts in payment_pending and moves to paid once
+// its payment is authorized, or to placed when it is paid on delivery. From
+// there it moves forward through
+// accepted -> preparing -> ready -> out_for_delivery -> delivered
+// where pickup orders skip out_for_delivery. It can leave that path by being
+// canceled or rejected, and a canceled or rejected order is refunded once its
+// payment has been given back in full.
+const (
+	StatusPaymentPending = "payment_pending"
+	StatusPaid           = "paid"
+	StatusPlaced         = "placed"
+	StatusAccepted       = "accepted"
+	StatusPreparing      = "preparing"
+	StatusReady          = "ready"
+	StatusOutForDelivery = "out_for_delivery"
+	StatusDelivered      = "delivered"
+	StatusCanceled       = "canceled"
+	StatusRejected       = "rejected"
+	StatusRefunded       = "refunded"
+)
+
+// orderTransitions lists the statuses an order may move to from each status
+var orderTransitions = map[string][]string{
+	StatusPaymentPending: {StatusPaid, StatusPlaced, StatusCanceled},
+	StatusPaid:           {StatusAccepted, StatusRejected, StatusCanceled},
+	StatusPlaced:         {StatusAccepted, StatusRejected, StatusCanceled},
+	StatusAccepted:       {StatusPreparing, StatusRejected, StatusCanceled},
+	StatusPreparing:      {StatusReady, StatusRejected},
+	StatusReady:          {StatusOutForDelivery, StatusDelivered},
+	StatusOutForDelivery: {StatusDelivered},
+	StatusCanceled:       {StatusRefunded},
+	StatusRejected:       {StatusRefunded},
+}
+
+// ActiveStatuses are the statuses of orders that are not finished yet
+var ActiveStatuses = []string{StatusPaymentPending, StatusPaid, StatusPlaced, StatusAccepted, StatusPreparing, StatusReady, StatusOutForDelivery}
+
+// IllegalTransitionError is returned when an order cannot move to a status
+// from the one it is in
+type IllegalTransitionError struct {
+	From string
+	To   string
+}
+
+var (
+	ErrIllegalTransition = errors.New("illegal order status transition")
+	ErrWrongFulfillment  = errors.New("order is not fulfilled this way")
+)
+
+func (e *IllegalTransitionError) Error() string {
+	return fmt.Sprintf("order cannot move from %s to %s", e.From, e.To)
+}
+
+func (e *IllegalTransitionError) Unwrap() error {
+	return ErrIllegalTransition
+}
+
+func CanTransition(from, to string) bool {
+	for _, next := range orderTransitions[from] {
+		if next == to {
+			return true
+		}
+	}
+	return false
+}
+
+// canMove reports whether order may move to status to. On top of the
+// lifecycle, a ready delivery order must go out for delivery before it is
+// delivered, and a ready pickup order is handed over without going out.
+func canMove(order Order, to string) bool {
+	if !CanTransition(order.Status, to) {
+		return false
+	}
+	if order.Status == StatusReady {
+		return (to == StatusOutForDelivery) == (order.Fulfillment == FulfillmentDelivery)
+	}
+	return true
+}
+
+// OrderStatusChange is one entry of an order's timeline. ActorID is nil for
+// changes made by the system.
+type OrderStatusChange struct {
+	FromStatus *string   `json:"from_status" db:"from_status"`
+	ToStatus   string    `json:"to_status" db:"to_status"`
+	ActorID    *int      `json:"actor_id" db:"actor_id"`
+	Reason     string    `json:"reason" db:"reason"`
+	CreatedAt  time.Time `json:"created_at" db:"created_at"`
+}
+
+// recordStatus appends an entry to the order's timeline and returns the event
+// to publish once the transaction commits. actorID 0 means the system.
+func recordStatus(tx *sqlx.Tx, orderID, userID int, from *string, to string, actorID int, reason string) (events.OrderEvent, error) {
+	e := events.OrderEvent{
+		Type:       orderEventType(from),
+		OrderID:    orderID,
+		UserID:     userID,
+		FromStatus: from,
+		Status:     to,
+		Reason:     reason,
+	}
+	err := tx.QueryRow(`
+		INSERT INTO order_status_history (order_id, from_status, to_status, actor_id, reason)
+		VALUES ($1, $2, $3, NULLIF($4, 0), $5)
+		RETURNING id, actor_id, created_at
+	`, orderID, from, to, actorID, reason).Scan(&e.ID, &e.ActorID, &e.At)
+	return e, err
+}
+
+// orderEventType tells the creation of an order, its first timeline entry,
+// from later status changes
+func orderEventType(from *string) string {
+	if from == nil {
+		return events.OrderCreated
+	}
+	return events.OrderStatusChanged
+}
+
+// commitOrderEvents queues webhook deliveries for the events recorded in tx,
+// commits it and then publishes the events
+func commitOrderEvents(tx *sqlx.Tx, evs ...events.OrderEvent) error {
+	if err := queueWebhooks(tx, evs); err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	events.Publish(evs...)
+	return nil
+}
+
+// lockOrder reads an order and locks it for the rest of the transaction
+func lockOrder(tx *sqlx.Tx, orderID int) (Order, error) {
+	var order Order
+	err := tx.Get(&order, `select `+orderColumns+` from orders where id = $1 for update`, orderID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return order, ErrOrderNotFound
+	}
+	return order, err
+}
+
+// transitionLocked moves an order locked by lockOrder to status to and
+// reloads it
+func transitionLocked(tx *sqlx.Tx, order *Order, to string, actorID int, reason string) (events.OrderEvent, error) {
+	if !canMove(*order, to) {
+		return events.OrderEvent{}, &IllegalTransitionError{From: order.Status, To: to}
+	}
+	from := order.Status
+	err := tx.Get(order, `
+		UPDATE orders
+		SET status = $1, delivered_at = CASE WHEN $1 = 'delivered' THEN now() ELSE delivered_at END
+		WHERE id = $2
+		RETURNING `+orderColumns, to, order.ID)
+	if err != nil {
+		return events.OrderEvent{}, err
+	}
+	return recordStatus(tx, order.ID, order.UserID, &from, to, actorID, reason)
+}
+
+// TransitionOrder moves an order to status to, rejecting moves the lifecycle
+// does not allow, and records who made the change and why. actorID 0 means
+// the system.
+func TransitionOrder(orderID int, to string, actorID int, reason string) (Order, error) {
+	return transitionOrder(orderID, "", to, actorID, reason)
+}
+
+// TransitionFulfilledOrder is TransitionOrder for orders of one fulfillment,
+// delivery or pickup; orders of the other fail with ErrWrongFulfillment
+func TransitionFulfilledOrder(orderID int, fulfillment, to string, actorID int) (Order, error) {
+	return transitionOrder(orderID, fulfillment, to, actorID, "")
+}
+
+func transitionOrder(orderID int, fulfillment, to string, actorID int, reason string) (Order, error) {
+	tx, err := db.DB.Beginx()
+	if err != nil {
+		return Order{}, err
+	}
+	order, err := lockOrder(tx, orderID)
+	if err != nil {
+		tx.Rollback()
+		return order, err
+	}
+	if fulfillment != "" && order.Fulfillment != fulfillment {
+		tx.Rollback()
+		return order, fmt.Errorf("%w: it is for %s", ErrWrongFulfillment, order.Fulfillment)
+	}
+	e, err := transitionLocked(tx, &order, to, actorID, reason)
+	if err != nil {
+		tx.Rollback()
+		return order, err
+	}
+	return order, commitOrderEvents(tx, e)
+}
+
+// GetOrderHistory returns the timeline of an order, oldest first
+func GetOrderHistory(orderID int) ([]OrderStatusChange, error) {
+	history := []OrderStatusChange{}
+	err := db.DB.Select(&history, `
+		SELECT from_status, to_status, actor_id, reason, created_at
+		FROM order_status_history
+		WHERE order_id = $1
+		ORDER BY created_at, id
+	`, orderID)
+	return history, err
+}
+
+// autoCompleteSteps is the next status on the way to delivered for each
+// active status; ready delivery orders go out for delivery first. Orders
+// waiting for payment are left alone.
+var autoCompleteSteps = map[string]string{
+	StatusPaid:           StatusAccepted,
+	StatusPlaced:         StatusAccepted,
+	StatusAccepted:       StatusPreparing,
+	StatusPreparing:      StatusReady,
+	StatusReady:          StatusDelivered,
+	StatusOutForDelivery: StatusDelivered,
+}
+
+// AutoCompleteOrders walks every active order older than age through the
+// lifecycle to delivered, recording each step as a system change. It returns
+// the IDs of the orders it completed.
+func AutoCompleteOrders(age time.Duration) ([]int, error) {
+	statuses := make([]string, 0, len(autoCompleteSteps))
+	for status := range autoCompleteSteps {
+		statuses = append(statuses, status)
+	}
+	var ids []int
+	err := db.DB.Select(&ids, `
+		SELECT id FROM orders
+		WHERE status = ANY($1) AND created_at < now() - make_interval(secs => $2)
+		ORDER BY id
+	`, pq.Array(statuses), age.Seconds())
+	if err != nil {
+		return nil, err
+	}
+	var completed []int
+	for _, id := range ids {
+		tx, err := db.DB.Beginx()
+		if err != nil {
+			return completed, err
+		}
+		var steps []events.OrderEvent
+		order, err := lockOrder(tx, id)
+		for err == nil && order.Status != StatusDelivered {
+			next, ok := autoCompleteSteps[order.Status]
+			if !ok {
+				// Canceled or rejected since it was selected
+				break
+			}
+			if order.Status == StatusReady && order.Fulfillment == FulfillmentDelivery {
+				next = StatusOutForDelivery
+			}
+			var e events.OrderEvent
+			e, err = transitionLocked(tx, &order, next, 0, "auto-completed")
+			steps = append(steps, e)
+		}
+		if err != nil {
+			tx.Rollback()
+			return completed, err
+		}
+		if err := commitOrderEvents(tx, steps...); err != nil {
+			return completed, err
+		}
+		if order.Status == StatusDelivered {
+			completed = append(completed, id)
+		}
+	}
+	return completed, nil
+}
+
+// ReplayStart returns the event ID to replay from for a client that last saw
+// event lastID. Events are published as their transactions commit, which is
+// not always in ID order, so an event with a lower ID may have been published
+// after lastID. Replay therefore also covers the events recorded within
+// overlap before lastID, and clients may see some events twice.
+func ReplayStart(lastID int64, overlap time.Duration) (int64, error) {
+	var start int64
+	err := db.DB.Get(&start, `
+		SELECT COALESCE(min(id) - 1, $1)
+		FROM order_status_history
+		WHERE id < $1
+			AND created_at >= (SELECT created_at FROM order_status_history WHERE id = $1) - make_interval(secs => $2)
+	`, lastID, overlap.Seconds())
+	return start, err
+}
+
+// LastEventID returns the ID of the newest order event, 0 when there is none
+func LastEventID() (int64, error) {
+	var id int64
+	err := db.DB.Get(&id, `SELECT COALESCE(max(id), 0) FROM order_status_history`)
+	return id, err
+}
+
+// GetOrderEventsSince rebuilds up to limit order events after event afterID
+// from the order timelines, oldest first, so clients that reconnect can catch
+// up. userID and orderID narrow the events to one customer or one order when
+// they are not 0.
+func GetOrderEventsSince(afterID int64, userID, orderID, limit int) ([]events.OrderEvent, error) {
+	var rows []struct {
+		ID         int64     `db:"id"`
+		OrderID    int       `db:"order_id"`
+		UserID     int       `db:"user_id"`
+		FromStatus *string   `db:"from_status"`
+		ToStatus   string    `db:"to_status"`
+		ActorID    *int      `db:"actor_id"`
+		Reason     string    `db:"reason"`
+		CreatedAt  time.Time `db:"created_at"`
+	}
+	err := db.DB.Select(&rows, `
+		SELECT h.id, h.order_id, o.user_id, h.from_status, h.to_status, h.actor_id, h.reason, h.created_at
+		FROM order_status_history h
+		JOIN orders o ON o.id = h.order_id
+		WHERE h.id > $1
+			AND ($2 = 0 OR o.user_id = $2)
+			AND ($3 = 0 OR h.order_id = $3)
+		ORDER BY h.id
+		LIMIT $4
+	`, afterID, userID, orderID, limit)
+	if err != nil {
+		return nil, err
+	}
+	evs := make([]events.OrderEvent, len(rows))
+	for i, row := range rows {
+		evs[i] = events.OrderEvent{
+			ID:         row.ID,
+			Type:       orderEventType(row.FromStatus),
+			OrderID:    row.OrderID,
+			UserID:     row.UserID,
+			FromStatus: row.FromStatus,
+			Status:     row.ToStatus,
+			ActorID:    row.ActorID,
+			Reason:     row.Reason,
+			At:         row.CreatedAt,
+		}
+	}
+	return evs, nil
+}
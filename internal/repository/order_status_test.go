@@ -0,0 +1,63 @@
+package repository
+
+import "testing"
+
+func TestCanTransition(t *testing.T) {
+	tests := []struct {
+		from, to string
+		want     bool
+	}{
+		{StatusPaymentPending, StatusPaid, true},
+		{StatusPaymentPending, StatusPlaced, true},
+		{StatusPaymentPending, StatusCanceled, true},
+		{StatusPaymentPending, StatusAccepted, false},
+		{StatusPaid, StatusAccepted, true},
+		{StatusPlaced, StatusAccepted, true},
+		{StatusPlaced, StatusRejected, true},
+		{StatusAccepted, StatusPreparing, true},
+		{StatusAccepted, StatusCanceled, true},
+		{StatusPreparing, StatusReady, true},
+		{StatusPreparing, StatusCanceled, false},
+		{StatusReady, StatusOutForDelivery, true},
+		{StatusReady, StatusDelivered, true},
+		{StatusOutForDelivery, StatusDelivered, true},
+		{StatusCanceled, StatusRefunded, true},
+		{StatusRejected, StatusRefunded, true},
+		// No going back, skipping ahead or leaving a final status
+		{StatusPreparing, StatusAccepted, false},
+		{StatusPlaced, StatusReady, false},
+		{StatusDelivered, StatusCanceled, false},
+		{StatusDelivered, StatusRefunded, false},
+		{StatusRefunded, StatusDelivered, false},
+		{StatusCanceled, StatusPlaced, false},
+		{StatusPlaced, StatusPlaced, false},
+		{"unknown", StatusPlaced, false},
+	}
+	for _, tt := range tests {
+		if got := CanTransition(tt.from, tt.to); got != tt.want {
+			t.Errorf("CanTransition(%q, %q) = %v, want %v", tt.from, tt.to, got, tt.want)
+		}
+	}
+}
+
+func TestCanMove(t *testing.T) {
+	tests := []struct {
+		status, fulfillment, to string
+		want                    bool
+	}{
+		{StatusReady, FulfillmentPickup, StatusDelivered, true},
+		{StatusReady, FulfillmentPickup, StatusOutForDelivery, false},
+		{StatusReady, FulfillmentDelivery, StatusOutForDelivery, true},
+		{StatusReady, FulfillmentDelivery, StatusDelivered, false},
+		{StatusOutForDelivery, FulfillmentDelivery, StatusDelivered, true},
+		{StatusPreparing, FulfillmentDelivery, StatusReady, true},
+		{StatusPreparing, FulfillmentPickup, StatusDelivered, false},
+		{StatusCanceled, FulfillmentPickup, StatusRefunded, true},
+	}
+	for _, tt := range tests {
+		order := Order{Status: tt.status, Fulfillment: tt.fulfillment}
+		if got := canMove(order, tt.to); got != tt.want {
+			t.Errorf("canMove(%s %s order, %q) = %v, want %v", tt.status, tt.fulfillment, tt.to, got, tt.want)
+		}
+	}
+}
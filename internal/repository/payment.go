@@ -0,0 +1,254 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/Anwarjondev/fast-food/internal/db"
+	"github.com/Anwarjondev/fast-food/internal/events"
+	"github.com/Anwarjondev/fast-food/internal/money"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+var ErrPaymentNotFound = errors.New("payment not found")
+
+// Payment statuses. A payment is pending until its provider decides, and
+// authorized or deferred payments are captured once the order is delivered.
+// An authorized payment of an order that was canceled or rejected is
+// captured for what was not refunded, or voided when everything was. A
+// captured payment whose whole amount was given back is refunded. Voiding or
+// refunding all of a payment moves a canceled or rejected order to refunded.
+const (
+	PaymentPending    = "pending"
+	PaymentAuthorized = "authorized"
+	PaymentDeferred   = "deferred"
+	PaymentDeclined   = "declined"
+	PaymentFailed     = "failed"
+	PaymentExpired    = "expired"
+	PaymentCaptured   = "captured"
+	PaymentRefunded   = "refunded"
+	PaymentVoided     = "voided"
+)
+
+// paymentOrderStatus is the status an order waiting for payment moves to
+// when its payment is decided
+var paymentOrderStatus = map[string]string{
+	PaymentAuthorized: StatusPaid,
+	PaymentDeferred:   StatusPlaced,
+	PaymentDeclined:   StatusCanceled,
+	PaymentFailed:     StatusCanceled,
+	PaymentExpired:    StatusCanceled,
+}
+
+// Payment is the payment of an order. Provider is the payment method and
+// Reference the payment's id at the provider; Error explains a decline or
+// failure. Refunded is the total of the order's refunds.
+type Payment struct {
+	ID        int         `json:"id" db:"id"`
+	OrderID   int         `json:"order_id" db:"order_id"`
+	Provider  string      `json:"provider" db:"provider"`
+	Reference *string     `json:"reference" db:"reference"`
+	Status    string      `json:"status" db:"status"`
+	Amount    money.Money `json:"amount" db:"amount"`
+	Refunded  money.Money `json:"refunded" db:"refunded"`
+	Error     string      `json:"error" db:"error"`
+	CreatedAt time.Time   `json:"created_at" db:"created_at"`
+	UpdatedAt time.Time   `json:"updated_at" db:"updated_at"`
+}
+
+const paymentColumns = `id, order_id, provider, reference, status,
+	amount AS "amount.amount", currency AS "amount.currency",
+	refunded AS "refunded.amount", currency AS "refunded.currency", error, created_at, updated_at`
+
+// insertPayment starts a pending payment for the total of an order
+func insertPayment(tx *sqlx.Tx, orderID int, provider string, amount money.Money) error {
+	_, err := tx.Exec(`
+		INSERT INTO payments (order_id, provider, status, amount, currency)
+		VALUES ($1, $2, $3, $4, $5)
+	`, orderID, provider, PaymentPending, amount.Amount, amount.Currency)
+	return err
+}
+
+// GetOrderPayment returns the payment of an order
+func GetOrderPayment(orderID int) (Payment, error) {
+	var p Payment
+	err := db.DB.Get(&p, `SELECT `+paymentColumns+` FROM payments WHERE order_id = $1`, orderID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return p, ErrPaymentNotFound
+	}
+	return p, err
+}
+
+// GetPaymentByReference finds a payment by its id at the provider
+func GetPaymentByReference(provider, reference string) (Payment, error) {
+	var p Payment
+	err := db.DB.Get(&p, `SELECT `+paymentColumns+` FROM payments WHERE provider = $1 AND reference = $2`, provider, reference)
+	if errors.Is(err, sql.ErrNoRows) {
+		return p, ErrPaymentNotFound
+	}
+	return p, err
+}
+
+// ResolvePayment records what the provider decided about the pending payment
+// of an order and moves the order on: authorized payments make it paid,
+// deferred ones placed, and declined, failed or expired ones cancel it and
+// put its foods back into stock. A still pending status only records the
+// reference, and a payment authorized after its order was canceled is
+// refunded. Payments that were already decided are left alone, so webhooks
+// may be delivered more than once. It returns the order as it is afterwards.
+func ResolvePayment(orderID int, status, reference, message string) (Order, error) {
+	tx, err := db.DB.Beginx()
+	if err != nil {
+		return Order{}, err
+	}
+	order, err := lockOrder(tx, orderID)
+	if err != nil {
+		tx.Rollback()
+		return order, err
+	}
+	res, err := tx.Exec(`
+		UPDATE payments
+		SET status = $1, reference = COALESCE(NULLIF($2, ''), reference), error = $3, updated_at = now()
+		WHERE order_id = $4 AND status = $5
+	`, status, reference, message, orderID, PaymentPending)
+	if err != nil {
+		tx.Rollback()
+		return order, err
+	}
+	n, _ := res.RowsAffected()
+	if n > 0 && status == PaymentAuthorized && order.Status == StatusCanceled {
+		if err := queueRefund(tx, orderID, nil, 0, "order canceled before payment completed"); err != nil {
+			tx.Rollback()
+			return order, err
+		}
+	}
+	next, decided := paymentOrderStatus[status]
+	if n == 0 || !decided || order.Status != StatusPaymentPending {
+		return order, tx.Commit()
+	}
+	reason := "payment " + status
+	if message != "" {
+		reason += ": " + message
+	}
+	e, err := transitionLocked(tx, &order, next, 0, reason)
+	if err != nil {
+		tx.Rollback()
+		return order, err
+	}
+	if next == StatusCanceled {
+		if err := restoreStock(tx, orderID); err != nil {
+			tx.Rollback()
+			return order, err
+		}
+	}
+	return order, commitOrderEvents(tx, e)
+}
+
+// GetExpiredPayments returns the orders whose payment has been pending for
+// longer than age
+func GetExpiredPayments(age time.Duration) ([]int, error) {
+	var ids []int
+	err := db.DB.Select(&ids, `
+		SELECT order_id FROM payments
+		WHERE status = $1 AND created_at < now() - make_interval(secs => $2)
+		ORDER BY order_id
+	`, PaymentPending, age.Seconds())
+	return ids, err
+}
+
+// ClaimPaymentsToSettle returns up to limit payments to capture or void and
+// keeps other workers from claiming them again for lease. These are the
+// authorized or deferred payments of delivered orders, and the authorized
+// payments of canceled or rejected orders, whose refunds are settled by
+// capturing only what is left of them, or voiding them when nothing is.
+func ClaimPaymentsToSettle(limit int, lease time.Duration) ([]Payment, error) {
+	var payments []Payment
+	err := db.DB.Select(&payments, `
+		UPDATE payments
+		SET next_attempt_at = now() + make_interval(secs => $4)
+		WHERE id IN (
+			SELECT p.id FROM payments p
+			JOIN orders o ON o.id = p.order_id
+			WHERE p.next_attempt_at <= now() AND (
+				(p.status = ANY($1) AND o.status = $2)
+				OR (p.status = $5 AND o.status = ANY($6))
+			)
+			ORDER BY p.next_attempt_at
+			LIMIT $3
+			FOR UPDATE OF p SKIP LOCKED
+		)
+		RETURNING `+paymentColumns,
+		pq.Array([]string{PaymentAuthorized, PaymentDeferred}), StatusDelivered, limit, lease.Seconds(),
+		PaymentAuthorized, pq.Array([]string{StatusCanceled, StatusRejected}))
+	return payments, err
+}
+
+// MarkPaymentSettled records that a payment was captured or voided, status
+// telling which. The pending refunds of the payment were taken off what was
+// captured, so they succeed with it, and a voided payment gave everything
+// back, so its canceled or rejected order is refunded.
+func MarkPaymentSettled(id int, status string) error {
+	tx, err := db.DB.Beginx()
+	if err != nil {
+		return err
+	}
+	var orderID int
+	if err := tx.Get(&orderID, `SELECT order_id FROM payments WHERE id = $1`, id); err != nil {
+		tx.Rollback()
+		return err
+	}
+	order, err := lockOrder(tx, orderID)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	_, err = tx.Exec(`UPDATE payments SET status = $1, error = '', updated_at = now() WHERE id = $2`, status, id)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	_, err = tx.Exec(`
+		UPDATE refunds SET status = $1, error = '', updated_at = now()
+		WHERE payment_id = $2 AND status = $3
+	`, RefundSucceeded, id, RefundPending)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	if status != PaymentVoided {
+		return tx.Commit()
+	}
+	evs, err := refundOrder(tx, &order)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	return commitOrderEvents(tx, evs...)
+}
+
+// refundOrder moves an order locked by lockOrder whose payment was given
+// back in full to refunded, if it was canceled or rejected. Delivered orders
+// keep their status.
+func refundOrder(tx *sqlx.Tx, order *Order) ([]events.OrderEvent, error) {
+	if order.Status != StatusCanceled && order.Status != StatusRejected {
+		return nil, nil
+	}
+	e, err := transitionLocked(tx, order, StatusRefunded, 0, "payment refunded")
+	if err != nil {
+		return nil, err
+	}
+	return []events.OrderEvent{e}, nil
+}
+
+// MarkCaptureFailed records a failed capture or void, to be tried again after
+// retryIn
+func MarkCaptureFailed(id int, message string, retryIn time.Duration) error {
+	_, err := db.DB.Exec(`
+		UPDATE payments
+		SET error = $1, next_attempt_at = now() + make_interval(secs => $2), updated_at = now()
+		WHERE id = $3
+	`, message, retryIn.Seconds(), id)
+	return err
+}
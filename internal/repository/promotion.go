@@ -0,0 +1,263 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/Anwarjondev/fast-food/internal/db"
+	"github.com/Anwarjondev/fast-food/internal/money"
+	"github.com/jmoiron/sqlx"
+)
+
+var (
+	ErrPromotionNotFound = errors.New("promotion not found")
+	ErrPromotionExists   = errors.New("promotion with this code already exists")
+
+	// Errors for promo codes entered with an order
+	ErrPromoCodeInvalid   = errors.New("promo code does not exist")
+	ErrPromoNotActive     = errors.New("promo code is not valid at this time")
+	ErrPromoUsedUp        = errors.New("promo code has been used up")
+	ErrPromoMinOrder      = errors.New("order is below the promo code minimum")
+	ErrPromoNotApplicable = errors.New("promo code does not apply to this order")
+)
+
+// Promotion kinds
+const (
+	PromoPercentage = "percentage"
+	PromoFixed      = "fixed"
+	PromoBuyXGetY   = "buy_x_get_y"
+)
+
+// Promotion is a promo code. Percent is the percent off for percentage codes
+// and Amount the amount off for fixed codes; a promotion only applies to
+// orders in the currency of Amount and MinOrder. CategoryID and FoodID limit
+// the lines it applies to. MaxUses and MaxUsesPerUser are unlimited when nil, and the
+// code is valid from StartsAt until EndsAt when they are set.
+type Promotion struct {
+	ID             int         `json:"id" db:"id"`
+	Code           string      `json:"code" db:"code"`
+	Kind           string      `json:"kind" db:"kind"`
+	Percent        float64     `json:"percent" db:"percent"`
+	Amount         money.Money `json:"amount" db:"amount"`
+	CategoryID     *int        `json:"category_id" db:"category_id"`
+	FoodID         *int        `json:"food_id" db:"food_id"`
+	BuyCount       int         `json:"buy_count" db:"buy_count"`
+	GetCount       int         `json:"get_count" db:"get_count"`
+	MinOrder       money.Money `json:"min_order" db:"min_order"`
+	MaxUses        *int        `json:"max_uses" db:"max_uses"`
+	MaxUsesPerUser *int        `json:"max_uses_per_user" db:"max_uses_per_user"`
+	StartsAt       *time.Time  `json:"starts_at" db:"starts_at"`
+	EndsAt         *time.Time  `json:"ends_at" db:"ends_at"`
+	IsActive       bool        `json:"is_active" db:"is_active"`
+	CreatedAt      time.Time   `json:"created_at" db:"created_at"`
+}
+
+const promotionColumns = `id, code, kind, percent, amount AS "amount.amount", currency AS "amount.currency",
+	category_id, food_id, buy_count, get_count, min_order AS "min_order.amount", currency AS "min_order.currency",
+	max_uses, max_uses_per_user, starts_at, ends_at, is_active, created_at`
+
+func GetPromotions() ([]Promotion, error) {
+	promotions := []Promotion{}
+	err := db.DB.Select(&promotions, `SELECT `+promotionColumns+` FROM promotions WHERE deleted_at IS NULL ORDER BY id`)
+	return promotions, err
+}
+
+func GetPromotion(id int) (Promotion, error) {
+	var promotion Promotion
+	err := db.DB.Get(&promotion, `SELECT `+promotionColumns+` FROM promotions WHERE id = $1 AND deleted_at IS NULL`, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return promotion, ErrPromotionNotFound
+	}
+	return promotion, err
+}
+
+func checkPromotion(p Promotion) error {
+	if p.CategoryID != nil {
+		if _, err := GetCategoryById(*p.CategoryID); err != nil {
+			return err
+		}
+	}
+	if p.FoodID != nil {
+		if _, err := GetFoodByID(*p.FoodID); err != nil {
+			return err
+		}
+	}
+	var exists bool
+	err := db.DB.Get(&exists, `
+		SELECT EXISTS(
+			SELECT 1 FROM promotions
+			WHERE lower(code) = lower($1) AND id <> $2 AND deleted_at IS NULL
+		)
+	`, p.Code, p.ID)
+	if err != nil {
+		return err
+	}
+	if exists {
+		return ErrPromotionExists
+	}
+	return nil
+}
+
+func CreatePromotion(p Promotion) (Promotion, error) {
+	if err := checkPromotion(p); err != nil {
+		return Promotion{}, err
+	}
+	var created Promotion
+	err := db.DB.Get(&created, `
+		INSERT INTO promotions (code, kind, percent, amount, currency, category_id, food_id, buy_count, get_count,
+			min_order, max_uses, max_uses_per_user, starts_at, ends_at, is_active)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15)
+		RETURNING `+promotionColumns,
+		p.Code, p.Kind, p.Percent, p.Amount.Amount, p.Amount.Currency, p.CategoryID, p.FoodID, p.BuyCount, p.GetCount,
+		p.MinOrder.Amount, p.MaxUses, p.MaxUsesPerUser, p.StartsAt, p.EndsAt, p.IsActive)
+	return created, err
+}
+
+func UpdatePromotion(p Promotion) (Promotion, error) {
+	if _, err := GetPromotion(p.ID); err != nil {
+		return Promotion{}, err
+	}
+	if err := checkPromotion(p); err != nil {
+		return Promotion{}, err
+	}
+	var updated Promotion
+	err := db.DB.Get(&updated, `
+		UPDATE promotions
+		SET code = $1, kind = $2, percent = $3, amount = $4, currency = $5, category_id = $6, food_id = $7,
+			buy_count = $8, get_count = $9, min_order = $10, max_uses = $11, max_uses_per_user = $12,
+			starts_at = $13, ends_at = $14, is_active = $15
+		WHERE id = $16 AND deleted_at IS NULL
+		RETURNING `+promotionColumns,
+		p.Code, p.Kind, p.Percent, p.Amount.Amount, p.Amount.Currency, p.CategoryID, p.FoodID,
+		p.BuyCount, p.GetCount, p.MinOrder.Amount, p.MaxUses, p.MaxUsesPerUser,
+		p.StartsAt, p.EndsAt, p.IsActive, p.ID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return updated, ErrPromotionNotFound
+	}
+	return updated, err
+}
+
+// DeletePromotion soft-deletes a promotion; orders that used it keep their discount
+func DeletePromotion(id int) error {
+	res, err := db.DB.Exec(`UPDATE promotions SET deleted_at = now() WHERE id = $1 AND deleted_at IS NULL`, id)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrPromotionNotFound
+	}
+	return nil
+}
+
+// pricedLine is an order line with what discounts need to know about it
+type pricedLine struct {
+	FoodID     int
+	CategoryID int
+	UnitPrice  money.Money
+	Count      int
+	TaxRate    float64
+}
+
+func (p Promotion) appliesTo(line pricedLine) bool {
+	return (p.FoodID == nil || *p.FoodID == line.FoodID) &&
+		(p.CategoryID == nil || *p.CategoryID == line.CategoryID)
+}
+
+// discount returns how much the promotion takes off an order of lines. The
+// lines must share one currency.
+func (p Promotion) discount(lines []pricedLine) (money.Money, error) {
+	currency := lines[0].UnitPrice.Currency
+	none := money.Zero(currency)
+	if p.Amount.Currency != currency {
+		return none, fmt.Errorf("%w: it is for orders in %s", ErrPromoNotApplicable, p.Amount.Currency)
+	}
+	subtotal, eligible := none, none
+	var units []money.Money
+	for _, line := range lines {
+		total := line.UnitPrice.Mul(line.Count)
+		subtotal = subtotal.Add(total)
+		if p.appliesTo(line) {
+			eligible = eligible.Add(total)
+			for i := 0; i < line.Count; i++ {
+				units = append(units, line.UnitPrice)
+			}
+		}
+	}
+	if subtotal.LessThan(p.MinOrder) {
+		return none, fmt.Errorf("%w of %s", ErrPromoMinOrder, p.MinOrder)
+	}
+	if len(units) == 0 {
+		return none, ErrPromoNotApplicable
+	}
+	discount := none
+	switch p.Kind {
+	case PromoPercentage:
+		discount = eligible.Percent(p.Percent)
+	case PromoFixed:
+		discount = p.Amount.Min(eligible)
+	case PromoBuyXGetY:
+		// The cheapest eligible units are the free ones
+		free := len(units) / (p.BuyCount + p.GetCount) * p.GetCount
+		if free == 0 {
+			return none, fmt.Errorf("%w: buy %d to get %d free", ErrPromoNotApplicable, p.BuyCount+p.GetCount, p.GetCount)
+		}
+		sort.Slice(units, func(i, j int) bool { return units[i].LessThan(units[j]) })
+		for _, price := range units[:free] {
+			discount = discount.Add(price)
+		}
+	default:
+		return none, fmt.Errorf("unknown promotion kind %q", p.Kind)
+	}
+	return discount, nil
+}
+
+// redeemPromotion checks a promo code entered by userID against lines and
+// returns the promotion and the discount it gives. It locks the promotion so
+// concurrent orders cannot exceed its usage limits.
+func redeemPromotion(tx *sqlx.Tx, userID int, code string, lines []pricedLine) (Promotion, money.Money, error) {
+	none := money.Zero(lines[0].UnitPrice.Currency)
+	var row struct {
+		Promotion
+		InWindow bool `db:"in_window"`
+	}
+	err := tx.Get(&row, `
+		SELECT `+promotionColumns+`,
+			(starts_at IS NULL OR starts_at <= now()) AND (ends_at IS NULL OR ends_at > now()) AS in_window
+		FROM promotions
+		WHERE lower(code) = lower($1) AND deleted_at IS NULL
+		FOR UPDATE
+	`, code)
+	if errors.Is(err, sql.ErrNoRows) {
+		return Promotion{}, none, ErrPromoCodeInvalid
+	}
+	if err != nil {
+		return Promotion{}, none, err
+	}
+	p := row.Promotion
+	if !p.IsActive || !row.InWindow {
+		return p, none, ErrPromoNotActive
+	}
+	if p.MaxUses != nil || p.MaxUsesPerUser != nil {
+		var uses struct {
+			Total int `db:"total"`
+			User  int `db:"by_user"`
+		}
+		err := tx.Get(&uses, `
+			SELECT count(*) AS total, count(*) FILTER (WHERE r.user_id = $2) AS by_user
+			FROM promotion_redemptions r
+			JOIN orders o ON o.id = r.order_id
+			WHERE r.promotion_id = $1 AND o.status NOT IN ($3, $4, $5)
+		`, p.ID, userID, StatusCanceled, StatusRejected, StatusRefunded)
+		if err != nil {
+			return p, none, err
+		}
+		if (p.MaxUses != nil && uses.Total >= *p.MaxUses) || (p.MaxUsesPerUser != nil && uses.User >= *p.MaxUsesPerUser) {
+			return p, none, ErrPromoUsedUp
+		}
+	}
+	discount, err := p.discount(lines)
+	return p, discount, err
+}
@@ -0,0 +1,54 @@
+package repository
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/Anwarjondev/fast-food/internal/money"
+)
+
+func TestPromotionDiscount(t *testing.T) {
+	intPtr := func(n int) *int { return &n }
+	usd := func(amount int64) money.Money { return money.New(amount, "USD") }
+	// 2 burgers at 5.00 and 3 drinks at 1.99, 15.97 in total
+	lines := []pricedLine{
+		{FoodID: 1, CategoryID: 1, UnitPrice: usd(500), Count: 2},
+		{FoodID: 2, CategoryID: 2, UnitPrice: usd(199), Count: 3},
+	}
+	tests := []struct {
+		name  string
+		promo Promotion
+		want  int64
+		err   error
+	}{
+		{"percentage rounds to cents", Promotion{Kind: PromoPercentage, Percent: 10}, 160, nil},
+		{"percentage of a category", Promotion{Kind: PromoPercentage, Percent: 7.5, CategoryID: intPtr(2)}, 45, nil},
+		{"fixed", Promotion{Kind: PromoFixed, Amount: usd(300)}, 300, nil},
+		{"fixed capped at eligible lines", Promotion{Kind: PromoFixed, Amount: usd(2000), CategoryID: intPtr(2)}, 597, nil},
+		{"buy 2 get 1 frees the cheapest unit", Promotion{Kind: PromoBuyXGetY, BuyCount: 2, GetCount: 1}, 199, nil},
+		{"buy 1 get 1 frees the cheapest units", Promotion{Kind: PromoBuyXGetY, BuyCount: 1, GetCount: 1}, 398, nil},
+		{"buy x get y on a food", Promotion{Kind: PromoBuyXGetY, BuyCount: 1, GetCount: 1, FoodID: intPtr(1)}, 500, nil},
+		{"buy x get y with too few units", Promotion{Kind: PromoBuyXGetY, BuyCount: 2, GetCount: 1, FoodID: intPtr(1)}, 0, ErrPromoNotApplicable},
+		{"below minimum order", Promotion{Kind: PromoFixed, Amount: usd(300), MinOrder: usd(2000)}, 0, ErrPromoMinOrder},
+		{"at minimum order", Promotion{Kind: PromoFixed, Amount: usd(300), MinOrder: usd(1597)}, 300, nil},
+		{"no eligible lines", Promotion{Kind: PromoPercentage, Percent: 10, FoodID: intPtr(9)}, 0, ErrPromoNotApplicable},
+		{"other currency", Promotion{Kind: PromoFixed, Amount: money.New(300, "EUR"), MinOrder: money.Zero("EUR")}, 0, ErrPromoNotApplicable},
+	}
+	for _, tt := range tests {
+		p := tt.promo
+		if p.Amount.Currency == "" {
+			p.Amount = usd(0)
+		}
+		if p.MinOrder.Currency == "" {
+			p.MinOrder = usd(0)
+		}
+		got, err := p.discount(lines)
+		if !errors.Is(err, tt.err) {
+			t.Errorf("%s: error = %v, want %v", tt.name, err, tt.err)
+			continue
+		}
+		if got != usd(tt.want) {
+			t.Errorf("%s: discount = %v, want %d", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,188 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/Anwarjondev/fast-food/internal/db"
+	"github.com/Anwarjondev/fast-food/internal/money"
+	"github.com/jmoiron/sqlx"
+)
+
+var ErrRefundTooLarge = errors.New("refund is more than what is left of the payment")
+
+// Refund statuses. A refund is pending until its provider confirms it, or
+// for a payment that was only authorized, until the payment is captured for
+// less or voided.
+const (
+	RefundPending   = "pending"
+	RefundSucceeded = "succeeded"
+)
+
+// Refund is money given back on the payment of an order. ActorID is who
+// canceled or rejected the order, nil for the system.
+type Refund struct {
+	ID        int         `json:"id" db:"id"`
+	OrderID   int         `json:"order_id" db:"order_id"`
+	PaymentID int         `json:"payment_id" db:"payment_id"`
+	Amount    money.Money `json:"amount" db:"amount"`
+	Reason    string      `json:"reason" db:"reason"`
+	ActorID   *int        `json:"actor_id" db:"actor_id"`
+	Status    string      `json:"status" db:"status"`
+	Reference *string     `json:"reference" db:"reference"`
+	Error     string      `json:"error" db:"error"`
+	CreatedAt time.Time   `json:"created_at" db:"created_at"`
+	UpdatedAt time.Time   `json:"updated_at" db:"updated_at"`
+}
+
+const refundColumns = `id, order_id, payment_id, amount AS "amount.amount", currency AS "amount.currency",
+	reason, actor_id, status, reference, error, created_at, updated_at`
+
+// queueRefund records a refund on the payment of an order locked by
+// lockOrder, for the payment job to send to the provider. A nil amount
+// refunds everything that is left of the payment. Only authorized and
+// captured payments hold money; for other orders, such as cash on delivery,
+// there is nothing to give back and any amount above zero is
+// ErrRefundTooLarge.
+func queueRefund(tx *sqlx.Tx, orderID int, amount *int64, actorID int, reason string) error {
+	var p Payment
+	err := tx.Get(&p, `SELECT `+paymentColumns+` FROM payments WHERE order_id = $1 FOR UPDATE`, orderID)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return err
+	}
+	var left int64
+	if err == nil && (p.Status == PaymentAuthorized || p.Status == PaymentCaptured) {
+		left = p.Amount.Sub(p.Refunded).Amount
+	}
+	refund := left
+	if amount != nil {
+		refund = *amount
+	}
+	if refund > left {
+		return ErrRefundTooLarge
+	}
+	if refund == 0 {
+		return nil
+	}
+	_, err = tx.Exec(`
+		INSERT INTO refunds (order_id, payment_id, amount, currency, reason, actor_id, status)
+		VALUES ($1, $2, $3, $4, $5, NULLIF($6, 0), $7)
+	`, orderID, p.ID, refund, p.Amount.Currency, reason, actorID, RefundPending)
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec(`UPDATE payments SET refunded = refunded + $1, updated_at = now() WHERE id = $2`, refund, p.ID)
+	return err
+}
+
+// GetOrderRefunds returns the refunds of an order, oldest first
+func GetOrderRefunds(orderID int) ([]Refund, error) {
+	refunds := []Refund{}
+	err := db.DB.Select(&refunds, `SELECT `+refundColumns+` FROM refunds WHERE order_id = $1 ORDER BY id`, orderID)
+	return refunds, err
+}
+
+// GetRefundReport returns the refunds issued from from until before to,
+// oldest first
+func GetRefundReport(from, to time.Time) ([]Refund, error) {
+	refunds := []Refund{}
+	err := db.DB.Select(&refunds, `
+		SELECT `+refundColumns+` FROM refunds
+		WHERE created_at >= $1 AND created_at < $2
+		ORDER BY created_at, id
+	`, from, to)
+	return refunds, err
+}
+
+// DueRefund is a pending refund with what is needed to send it
+type DueRefund struct {
+	ID               int         `db:"id"`
+	OrderID          int         `db:"order_id"`
+	Provider         string      `db:"provider"`
+	PaymentReference *string     `db:"payment_reference"`
+	Amount           money.Money `db:"amount"`
+}
+
+// ClaimRefunds picks up to limit pending refunds of captured payments that
+// are due and pushes their next attempt lease into the future, so other
+// replicas do not send them at the same time. Refunds of payments that are
+// only authorized are settled with the payment by ClaimPaymentsToSettle.
+func ClaimRefunds(limit int, lease time.Duration) ([]DueRefund, error) {
+	var due []DueRefund
+	err := db.DB.Select(&due, `
+		UPDATE refunds r
+		SET next_attempt_at = now() + make_interval(secs => $3)
+		FROM payments p
+		WHERE p.id = r.payment_id AND r.id IN (
+			SELECT rr.id FROM refunds rr
+			JOIN payments pp ON pp.id = rr.payment_id
+			WHERE rr.status = $1 AND rr.next_attempt_at <= now() AND pp.status = $4
+			ORDER BY rr.next_attempt_at
+			LIMIT $2
+			FOR UPDATE OF rr SKIP LOCKED
+		)
+		RETURNING r.id, r.order_id, p.provider, p.reference AS payment_reference,
+			r.amount AS "amount.amount", r.currency AS "amount.currency"
+	`, RefundPending, limit, lease.Seconds(), PaymentCaptured)
+	return due, err
+}
+
+// MarkRefundSucceeded records that the provider gave the money back. Once
+// every refund of a payment went through and nothing is left of it, the
+// payment is refunded, and so is its canceled or rejected order.
+func MarkRefundSucceeded(id int, reference string) error {
+	tx, err := db.DB.Beginx()
+	if err != nil {
+		return err
+	}
+	var orderID int
+	if err := tx.Get(&orderID, `SELECT order_id FROM refunds WHERE id = $1`, id); err != nil {
+		tx.Rollback()
+		return err
+	}
+	order, err := lockOrder(tx, orderID)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	var paymentID int
+	err = tx.Get(&paymentID, `
+		UPDATE refunds
+		SET status = $1, reference = NULLIF($2, ''), error = '', updated_at = now()
+		WHERE id = $3
+		RETURNING payment_id
+	`, RefundSucceeded, reference, id)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	res, err := tx.Exec(`
+		UPDATE payments SET status = $1, updated_at = now()
+		WHERE id = $2 AND refunded >= amount
+			AND NOT EXISTS (SELECT 1 FROM refunds WHERE payment_id = $2 AND status = $3)
+	`, PaymentRefunded, paymentID, RefundPending)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return tx.Commit()
+	}
+	evs, err := refundOrder(tx, &order)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	return commitOrderEvents(tx, evs...)
+}
+
+// MarkRefundFailed records a failed attempt, to be tried again after retryIn
+func MarkRefundFailed(id int, message string, retryIn time.Duration) error {
+	_, err := db.DB.Exec(`
+		UPDATE refunds
+		SET error = $1, next_attempt_at = now() + make_interval(secs => $2), updated_at = now()
+		WHERE id = $3
+	`, message, retryIn.Seconds(), id)
+	return err
+}
@@ -0,0 +1,47 @@
+package repository
+
+import (
+	"time"
+
+	"github.com/Anwarjondev/fast-food/internal/db"
+	"github.com/Anwarjondev/fast-food/internal/money"
+	"github.com/lib/pq"
+)
+
+// UnsoldStatuses are the statuses of orders that do not count as sales
+var UnsoldStatuses = []string{StatusCanceled, StatusRejected, StatusRefunded}
+
+// SalesDay sums the orders placed on one day in one currency. Tax is the tax
+// contained in Total, whether it was added to the prices or included in them.
+type SalesDay struct {
+	Day           string      `json:"day" db:"day"`
+	Orders        int         `json:"orders" db:"orders"`
+	Subtotal      money.Money `json:"subtotal" db:"subtotal"`
+	Discount      money.Money `json:"discount" db:"discount"`
+	ServiceCharge money.Money `json:"service_charge" db:"service_charge"`
+	PackagingFee  money.Money `json:"packaging_fee" db:"packaging_fee"`
+	DeliveryFee   money.Money `json:"delivery_fee" db:"delivery_fee"`
+	Tax           money.Money `json:"tax" db:"tax"`
+	Total         money.Money `json:"total" db:"total"`
+}
+
+// GetSalesReport sums the orders placed from from until before to per day
+// and currency, leaving out canceled, rejected and refunded orders
+func GetSalesReport(from, to time.Time) ([]SalesDay, error) {
+	days := []SalesDay{}
+	err := db.DB.Select(&days, `
+		SELECT to_char(created_at, 'YYYY-MM-DD') AS day, count(*) AS orders,
+			sum(subtotal)::BIGINT AS "subtotal.amount", currency AS "subtotal.currency",
+			sum(discount_amount)::BIGINT AS "discount.amount", currency AS "discount.currency",
+			sum(service_charge)::BIGINT AS "service_charge.amount", currency AS "service_charge.currency",
+			sum(packaging_fee)::BIGINT AS "packaging_fee.amount", currency AS "packaging_fee.currency",
+			sum(delivery_fee)::BIGINT AS "delivery_fee.amount", currency AS "delivery_fee.currency",
+			sum(tax_amount)::BIGINT AS "tax.amount", currency AS "tax.currency",
+			sum(total_amount)::BIGINT AS "total.amount", currency AS "total.currency"
+		FROM orders
+		WHERE created_at >= $1 AND created_at < $2 AND status <> ALL($3)
+		GROUP BY day, currency
+		ORDER BY day, currency
+	`, from, to, pq.Array(UnsoldStatuses))
+	return days, err
+}
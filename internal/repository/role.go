@@ -0,0 +1,52 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+
+	"github.com/Anwarjondev/fast-food/internal/db"
+)
+
+const (
+	RoleCustomer = "customer"
+	RoleCashier  = "cashier"
+	RoleKitchen  = "kitchen"
+	RoleCourier  = "courier"
+	RoleAdmin    = "admin"
+)
+
+var ErrUserNotFound = errors.New("user not found")
+
+func userExists(userID int) error {
+	var id int
+	err := db.DB.Get(&id, `SELECT id FROM users WHERE id = $1`, userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrUserNotFound
+	}
+	return err
+}
+
+func GetUserRoles(userID int) ([]string, error) {
+	if err := userExists(userID); err != nil {
+		return nil, err
+	}
+	roles := []string{}
+	err := db.DB.Select(&roles, `SELECT role FROM user_roles WHERE user_id = $1 ORDER BY role`, userID)
+	return roles, err
+}
+
+func GrantRole(userID int, role string) error {
+	if err := userExists(userID); err != nil {
+		return err
+	}
+	_, err := db.DB.Exec(`INSERT INTO user_roles (user_id, role) VALUES ($1, $2) ON CONFLICT DO NOTHING`, userID, role)
+	return err
+}
+
+func RevokeRole(userID int, role string) error {
+	if err := userExists(userID); err != nil {
+		return err
+	}
+	_, err := db.DB.Exec(`DELETE FROM user_roles WHERE user_id = $1 AND role = $2`, userID, role)
+	return err
+}
@@ -0,0 +1,165 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/Anwarjondev/fast-food/internal/db"
+	"github.com/Anwarjondev/fast-food/internal/utils"
+	"github.com/jmoiron/sqlx"
+)
+
+var (
+	ErrInvalidRefreshToken = errors.New("invalid or expired refresh token")
+	ErrRefreshTokenReused  = errors.New("refresh token was already used, session revoked")
+)
+
+type TokenPair struct {
+	UserID           int       `json:"-"`
+	SessionID        int       `json:"-"`
+	AccessToken      string    `json:"access_token"`
+	AccessExpiresAt  time.Time `json:"access_expires_at"`
+	RefreshToken     string    `json:"refresh_token"`
+	RefreshExpiresAt time.Time `json:"refresh_expires_at"`
+}
+
+func newTokenPair(accessTTL, refreshTTL time.Duration) (TokenPair, error) {
+	var pair TokenPair
+	var err error
+	if pair.AccessToken, err = utils.GenerateToken(); err != nil {
+		return pair, err
+	}
+	if pair.RefreshToken, err = utils.GenerateToken(); err != nil {
+		return pair, err
+	}
+	now := time.Now()
+	pair.AccessExpiresAt = now.Add(accessTTL)
+	pair.RefreshExpiresAt = now.Add(refreshTTL)
+	return pair, nil
+}
+
+func saveRefreshToken(tx *sqlx.Tx, sessionID int, pair TokenPair) error {
+	_, err := tx.Exec(`Insert into refresh_tokens(token_hash, session_id, expires_at) values($1, $2, $3)`,
+		utils.HashToken(pair.RefreshToken), sessionID, pair.RefreshExpiresAt)
+	return err
+}
+
+// CreateSession starts a new session for a device and returns its tokens
+func CreateSession(userID int, userAgent, ip string, accessTTL, refreshTTL time.Duration) (TokenPair, error) {
+	pair, err := newTokenPair(accessTTL, refreshTTL)
+	if err != nil {
+		return pair, err
+	}
+	tx, err := db.DB.Beginx()
+	if err != nil {
+		return TokenPair{}, err
+	}
+	var sessionID int
+	err = tx.QueryRow(`
+		Insert into sessions(user_id, access_token_hash, access_expires_at, user_agent, ip)
+		values($1, $2, $3, $4, $5) returning id
+	`, userID, utils.HashToken(pair.AccessToken), pair.AccessExpiresAt, userAgent, ip).Scan(&sessionID)
+	if err != nil {
+		tx.Rollback()
+		return TokenPair{}, err
+	}
+	pair.UserID, pair.SessionID = userID, sessionID
+	if err := saveRefreshToken(tx, sessionID, pair); err != nil {
+		tx.Rollback()
+		return TokenPair{}, err
+	}
+	return pair, tx.Commit()
+}
+
+// RefreshSession rotates the tokens of the session the refresh token belongs
+// to. A refresh token can be used only once: presenting it again means it was
+// stolen, so the whole session is revoked.
+func RefreshSession(refreshToken string, accessTTL, refreshTTL time.Duration) (TokenPair, error) {
+	tx, err := db.DB.Beginx()
+	if err != nil {
+		return TokenPair{}, err
+	}
+	var token struct {
+		SessionID int          `db:"session_id"`
+		UserID    int          `db:"user_id"`
+		ExpiresAt time.Time    `db:"expires_at"`
+		UsedAt    sql.NullTime `db:"used_at"`
+		RevokedAt sql.NullTime `db:"revoked_at"`
+	}
+	err = tx.Get(&token, `
+		Select r.session_id, s.user_id, r.expires_at, r.used_at, s.revoked_at
+		from refresh_tokens r
+		join sessions s on s.id = r.session_id
+		where r.token_hash = $1
+		for update of r, s
+	`, utils.HashToken(refreshToken))
+	if errors.Is(err, sql.ErrNoRows) {
+		tx.Rollback()
+		return TokenPair{}, ErrInvalidRefreshToken
+	}
+	if err != nil {
+		tx.Rollback()
+		return TokenPair{}, err
+	}
+	if token.RevokedAt.Valid || token.ExpiresAt.Before(time.Now()) {
+		tx.Rollback()
+		return TokenPair{}, ErrInvalidRefreshToken
+	}
+	if token.UsedAt.Valid {
+		if _, err := tx.Exec(`Update sessions set revoked_at = now() where id = $1`, token.SessionID); err != nil {
+			tx.Rollback()
+			return TokenPair{}, err
+		}
+		if err := tx.Commit(); err != nil {
+			return TokenPair{}, err
+		}
+		return TokenPair{}, ErrRefreshTokenReused
+	}
+
+	_, err = tx.Exec(`Update refresh_tokens set used_at = now() where token_hash = $1`, utils.HashToken(refreshToken))
+	if err != nil {
+		tx.Rollback()
+		return TokenPair{}, err
+	}
+	pair, err := newTokenPair(accessTTL, refreshTTL)
+	if err != nil {
+		tx.Rollback()
+		return TokenPair{}, err
+	}
+	pair.UserID, pair.SessionID = token.UserID, token.SessionID
+	_, err = tx.Exec(`
+		Update sessions set access_token_hash = $1, access_expires_at = $2, last_refreshed_at = now()
+		where id = $3
+	`, utils.HashToken(pair.AccessToken), pair.AccessExpiresAt, token.SessionID)
+	if err != nil {
+		tx.Rollback()
+		return TokenPair{}, err
+	}
+	if err := saveRefreshToken(tx, token.SessionID, pair); err != nil {
+		tx.Rollback()
+		return TokenPair{}, err
+	}
+	return pair, tx.Commit()
+}
+
+// SessionLive reports whether a session of the user is not revoked. JWT
+// access tokens carry their session, so revoking it ends them as well.
+func SessionLive(userID, sessionID int) (bool, error) {
+	var live bool
+	err := db.DB.Get(&live, `
+		Select exists(Select 1 from sessions where id = $1 and user_id = $2 and revoked_at is null)
+	`, sessionID, userID)
+	return live, err
+}
+
+func RevokeSession(sessionID int) error {
+	_, err := db.DB.Exec(`Update sessions set revoked_at = now() where id = $1 and revoked_at is null`, sessionID)
+	return err
+}
+
+// RevokeUserSessions logs the user out on every device
+func RevokeUserSessions(userID int) error {
+	_, err := db.DB.Exec(`Update sessions set revoked_at = now() where user_id = $1 and revoked_at is null`, userID)
+	return err
+}
@@ -0,0 +1,134 @@
+package repository
+
+import (
+	"sort"
+
+	"github.com/Anwarjondev/fast-food/internal/db"
+	"github.com/Anwarjondev/fast-food/internal/money"
+	"github.com/jmoiron/sqlx"
+)
+
+// Charges are the taxes and fees added to orders. TaxRate and ServiceCharge
+// are percentages and PackagingFee is in minor units of the order currency.
+// With TaxInclusive, food prices already contain tax and the tax of an order
+// is only itemized, not added to its total. Delivery prices delivery orders.
+type Charges struct {
+	TaxInclusive  bool
+	TaxRate       float64
+	ServiceCharge float64
+	PackagingFee  int64
+	Delivery      DeliveryRates
+}
+
+// OrderTax is the tax of an order at one rate
+type OrderTax struct {
+	Rate    float64     `json:"rate" db:"rate"`
+	Taxable money.Money `json:"taxable" db:"taxable"`
+	Amount  money.Money `json:"amount" db:"amount"`
+}
+
+// orderTotals is the money breakdown of an order
+type orderTotals struct {
+	Subtotal      money.Money
+	Discount      money.Money
+	ServiceCharge money.Money
+	PackagingFee  money.Money
+	DeliveryFee   money.Money
+	Tax           money.Money
+	Total         money.Money
+	Taxes         []OrderTax
+}
+
+// totals prices lines after a discount that was given on the lines for which
+// discounted returns true. The discount is spread over those lines in
+// proportion to their totals so that each is taxed at its own rate; the
+// service charge, packaging fee and delivery fee are taxed at the default
+// rate.
+func (c Charges) totals(lines []pricedLine, discount money.Money, discounted func(pricedLine) bool, deliveryFee money.Money) orderTotals {
+	currency := discount.Currency
+	t := orderTotals{
+		Subtotal:      money.Zero(currency),
+		Discount:      discount,
+		ServiceCharge: money.Zero(currency),
+		PackagingFee:  money.New(c.PackagingFee, currency),
+		DeliveryFee:   deliveryFee,
+		Tax:           money.Zero(currency),
+	}
+	totals := make([]money.Money, len(lines))
+	weights := make([]money.Money, len(lines))
+	for i, line := range lines {
+		totals[i] = line.UnitPrice.Mul(line.Count)
+		t.Subtotal = t.Subtotal.Add(totals[i])
+		weights[i] = money.Zero(currency)
+		if discounted(line) {
+			weights[i] = totals[i]
+		}
+	}
+	shares := discount.Allocate(weights)
+	net := t.Subtotal.Sub(discount)
+	t.ServiceCharge = net.Percent(c.ServiceCharge)
+
+	taxable := map[float64]money.Money{}
+	addTaxable := func(rate float64, amount money.Money) {
+		if base, ok := taxable[rate]; ok {
+			amount = base.Add(amount)
+		}
+		taxable[rate] = amount
+	}
+	for i, line := range lines {
+		addTaxable(line.TaxRate, totals[i].Sub(shares[i]))
+	}
+	if fees := t.ServiceCharge.Add(t.PackagingFee).Add(t.DeliveryFee); !fees.IsZero() {
+		addTaxable(c.TaxRate, fees)
+	}
+	rates := make([]float64, 0, len(taxable))
+	for rate := range taxable {
+		rates = append(rates, rate)
+	}
+	sort.Float64s(rates)
+	for _, rate := range rates {
+		if rate == 0 {
+			continue
+		}
+		base := taxable[rate]
+		tax := base.Percent(rate)
+		if c.TaxInclusive {
+			tax = base.IncludedPercent(rate)
+		}
+		t.Taxes = append(t.Taxes, OrderTax{Rate: rate, Taxable: base, Amount: tax})
+		t.Tax = t.Tax.Add(tax)
+	}
+
+	t.Total = net.Add(t.ServiceCharge).Add(t.PackagingFee).Add(t.DeliveryFee)
+	if !c.TaxInclusive {
+		t.Total = t.Total.Add(t.Tax)
+	}
+	return t
+}
+
+// insertOrderTaxes stores the tax breakdown of an order
+func insertOrderTaxes(tx *sqlx.Tx, orderID int, taxes []OrderTax) error {
+	for _, tax := range taxes {
+		_, err := tx.Exec(`
+			INSERT INTO order_taxes (order_id, rate, taxable, amount)
+			VALUES ($1, $2, $3, $4)
+		`, orderID, tax.Rate, tax.Taxable.Amount, tax.Amount.Amount)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func getOrderTaxes(orderID int) ([]OrderTax, error) {
+	taxes := []OrderTax{}
+	err := db.DB.Select(&taxes, `
+		SELECT t.rate, t.taxable AS "taxable.amount", o.currency AS "taxable.currency",
+			t.amount AS "amount.amount", o.currency AS "amount.currency"
+		FROM order_taxes t
+		JOIN orders o ON o.id = t.order_id
+		WHERE t.order_id = $1
+		ORDER BY t.rate
+	`, orderID)
+	return taxes, err
+}
@@ -0,0 +1,98 @@
+package repository
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/Anwarjondev/fast-food/internal/money"
+)
+
+func TestChargesTotals(t *testing.T) {
+	usd := func(amount int64) money.Money { return money.New(amount, "USD") }
+	all := func(pricedLine) bool { return true }
+	tests := []struct {
+		name        string
+		charges     Charges
+		lines       []pricedLine
+		discount    money.Money
+		discounted  func(pricedLine) bool
+		deliveryFee money.Money
+		want        orderTotals
+	}{
+		{
+			name:        "tax on top",
+			charges:     Charges{TaxRate: 12},
+			lines:       []pricedLine{{FoodID: 1, UnitPrice: usd(500), Count: 2, TaxRate: 12}},
+			discount:    usd(0),
+			discounted:  all,
+			deliveryFee: usd(0),
+			want: orderTotals{
+				Subtotal: usd(1000), Discount: usd(0), ServiceCharge: usd(0), PackagingFee: usd(0),
+				DeliveryFee: usd(0), Tax: usd(120), Total: usd(1120),
+				Taxes: []OrderTax{{Rate: 12, Taxable: usd(1000), Amount: usd(120)}},
+			},
+		},
+		{
+			name:        "tax included in prices",
+			charges:     Charges{TaxInclusive: true, TaxRate: 12},
+			lines:       []pricedLine{{FoodID: 1, UnitPrice: usd(1120), Count: 1, TaxRate: 12}},
+			discount:    usd(0),
+			discounted:  all,
+			deliveryFee: usd(0),
+			want: orderTotals{
+				Subtotal: usd(1120), Discount: usd(0), ServiceCharge: usd(0), PackagingFee: usd(0),
+				DeliveryFee: usd(0), Tax: usd(120), Total: usd(1120),
+				Taxes: []OrderTax{{Rate: 12, Taxable: usd(1120), Amount: usd(120)}},
+			},
+		},
+		{
+			name:        "discount spread over rates",
+			charges:     Charges{TaxRate: 10},
+			lines:       []pricedLine{{FoodID: 2, UnitPrice: usd(400), Count: 1, TaxRate: 20}, {FoodID: 1, UnitPrice: usd(300), Count: 2, TaxRate: 10}},
+			discount:    usd(100),
+			discounted:  all,
+			deliveryFee: usd(0),
+			want: orderTotals{
+				Subtotal: usd(1000), Discount: usd(100), ServiceCharge: usd(0), PackagingFee: usd(0),
+				DeliveryFee: usd(0), Tax: usd(126), Total: usd(1026),
+				Taxes: []OrderTax{
+					{Rate: 10, Taxable: usd(540), Amount: usd(54)},
+					{Rate: 20, Taxable: usd(360), Amount: usd(72)},
+				},
+			},
+		},
+		{
+			name:        "fees taxed at the default rate",
+			charges:     Charges{TaxRate: 12, ServiceCharge: 10, PackagingFee: 50},
+			lines:       []pricedLine{{FoodID: 1, CategoryID: 1, UnitPrice: usd(1000), Count: 1, TaxRate: 12}, {FoodID: 2, CategoryID: 2, UnitPrice: usd(500), Count: 1}},
+			discount:    usd(100),
+			discounted:  func(line pricedLine) bool { return line.CategoryID == 1 },
+			deliveryFee: usd(200),
+			want: orderTotals{
+				Subtotal: usd(1500), Discount: usd(100), ServiceCharge: usd(140), PackagingFee: usd(50),
+				DeliveryFee: usd(200), Tax: usd(155), Total: usd(1945),
+				Taxes: []OrderTax{{Rate: 12, Taxable: usd(1290), Amount: usd(155)}},
+			},
+		},
+		{
+			name:        "no tax",
+			charges:     Charges{},
+			lines:       []pricedLine{{FoodID: 1, UnitPrice: usd(250), Count: 3}},
+			discount:    usd(0),
+			discounted:  all,
+			deliveryFee: usd(0),
+			want: orderTotals{
+				Subtotal: usd(750), Discount: usd(0), ServiceCharge: usd(0), PackagingFee: usd(0),
+				DeliveryFee: usd(0), Tax: usd(0), Total: usd(750),
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.charges.totals(tt.lines, tt.discount, tt.discounted, tt.deliveryFee)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("totals =\n%+v\nwant\n%+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -1,85 +1,139 @@
 package repository
 
 import (
+	"database/sql"
+	"errors"
+
 	"github.com/Anwarjondev/fast-food/internal/db"
+	"github.com/Anwarjondev/fast-food/internal/utils"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
 )
 
+var ErrInvalidCredentials = errors.New("invalid email or password")
+
 type User struct {
-	ID    int    `json:"id"`
-	Email string `json:"email"`
+	ID        int            `json:"id" db:"id"`
+	Email     string         `json:"email" db:"email"`
+	Roles     pq.StringArray `json:"roles" db:"roles"`
+	SessionID int            `json:"-" db:"session_id"`
 }
 
-func CreateUser(email, password string) (int, error) {
+// CreateUser inserts an inactive user with the customer role.
+// passwordHash must come from utils.HashPassword.
+func CreateUser(email, passwordHash string) (int, error) {
 	var id int
-	err := db.DB.QueryRow(`Insert into users(email, password, is_active) values($1, $2, false) returning id`, email, password).Scan(&id)
+	err := db.DB.QueryRow(`
+		WITH u AS (
+			Insert into users(email, password, is_active) values($1, $2, false) returning id
+		)
+		Insert into user_roles(user_id, role) select id, $3 from u returning user_id
+	`, email, passwordHash, RoleCustomer).Scan(&id)
 	return id, err
 }
 
-func SaveToken(userId int, token string) error {
-	_, err := db.DB.Exec(`Update users set token = $1 where id = $2`, token, userId)
+// lockUser locks the row of a user for the rest of the transaction, so that
+// changes checked against the user's other rows do not race each other
+func lockUser(tx *sqlx.Tx, userID int) error {
+	_, err := tx.Exec(`Select 1 from users where id = $1 for update`, userID)
 	return err
 }
 
+// GetUserByToken resolves an access token of a live session
 func GetUserByToken(token string) (*User, error) {
 	var user User
-	err := db.DB.Get(&user, `Select id, email from users where token = $1`, token)
+	err := db.DB.Get(&user, `
+		Select u.id, u.email, s.id AS session_id,
+			COALESCE(array_agg(r.role) FILTER (WHERE r.role IS NOT NULL), '{}') AS roles
+		from sessions s
+		join users u on u.id = s.user_id
+		left join user_roles r on r.user_id = u.id
+		where s.access_token_hash = $1 and s.revoked_at is null and s.access_expires_at > now()
+		group by u.id, s.id
+	`, utils.HashToken(token))
 	if err != nil {
 		return nil, err
 	}
 	return &user, nil
 }
 
-func SaveConfirmaion(userId int, code int) error {
-	_, err := db.DB.Exec(`Insert into confirm(user_id, code, is_passwed, created_at) values($1, $2, false, now())`, userId, code)
-	return err
-}
-
 func ActiveUser(UserID int) error {
 	_, err := db.DB.Exec(`Update "users" Set is_active = true where id = $1`, UserID)
 	return err
 }
 
-func CheckCode(UserID, code int) (bool, error) {
-	var count int
-	err := db.DB.Get(&count, `Select count(*) from confirm where user_id = $1 and code = $2 and is_passwed = false`, UserID, code)
-	if err != nil {
-		return false, err
+func GetUserIDByEmail(email string) (int, error) {
+	var userID int
+	err := db.DB.Get(&userID, `Select id from users where email = $1`, email)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, ErrUserNotFound
 	}
-	return count > 0, nil
-}
-
-func MarkCodeAsPassed(UserID int) error {
-	_, err := db.DB.Exec(`Update confirm set is_passwed = true where user_id = $1`, UserID)
-	return err
+	return userID, err
 }
 
-func LoginUser(email, password string) (int, error) {
-	var id int
-	err := db.DB.Get(&id, `Select id from users where email = $1 and password = $2 and is_active = true`, email, password)
+// LoginUser checks the password of an active user. Passwords stored in
+// plaintext or with a different cost are re-hashed with cost on success.
+func LoginUser(email, password string, cost int) (int, error) {
+	var user struct {
+		ID       int    `db:"id"`
+		Password string `db:"password"`
+	}
+	err := db.DB.Get(&user, `Select id, COALESCE(password, '') AS password from users where email = $1 and is_active = true`, email)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, ErrInvalidCredentials
+	}
 	if err != nil {
 		return 0, err
 	}
-	_, err = db.DB.Exec(`Update "users" set is_logged_in = true where id = $1`, id)
-	return id, err
-}
-
-func LogoutUser(UserID int) error {
-	_, err := db.DB.Exec(`Update "users" set is_logged_in = false where id = $1`, UserID)
-	return err
+	ok, needsRehash := utils.CheckPassword(user.Password, password, cost)
+	if !ok {
+		return 0, ErrInvalidCredentials
+	}
+	if needsRehash {
+		hash, err := utils.HashPassword(password, cost)
+		if err != nil {
+			return 0, err
+		}
+		if err := UpdatePassword(user.ID, hash); err != nil {
+			return 0, err
+		}
+	}
+	return user.ID, nil
 }
 
-func UpdatePassword(userID int, newPassword string) error {
-	_, err := db.DB.Exec(`Update users set password = $1 where id = $2`, newPassword, userID)
+// UpdatePassword stores a hash produced by utils.HashPassword
+func UpdatePassword(userID int, passwordHash string) error {
+	_, err := db.DB.Exec(`Update users set password = $1 where id = $2`, passwordHash, userID)
 	return err
 }
 
-func GetUserIDByCode(code int) (int, error) {
-	var userID int
-	err := db.DB.Get(&userID, `
-		SELECT user_id 
-		FROM confirm 
-		WHERE code = $1 
-		AND is_passwed = false
-	`, code)
-	return userID, err
+// ResetPassword checks the reset code of the user and, in the same
+// transaction, stores a new password hash and revokes every session of the
+// user, so a stolen session does not survive the reset. The code is only
+// used up when the password is changed.
+func ResetPassword(userID int, code string, maxAttempts int, passwordHash string) error {
+	tx, err := db.DB.Beginx()
+	if err != nil {
+		return err
+	}
+	err = useCode(tx, userID, CodePurposeReset, code, maxAttempts)
+	if errors.Is(err, ErrCodeInvalid) {
+		if err := tx.Commit(); err != nil {
+			return err
+		}
+		return ErrCodeInvalid
+	}
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	if _, err := tx.Exec(`Update users set password = $1 where id = $2`, passwordHash, userID); err != nil {
+		tx.Rollback()
+		return err
+	}
+	if _, err := tx.Exec(`Update sessions set revoked_at = now() where user_id = $1 and revoked_at is null`, userID); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
 }
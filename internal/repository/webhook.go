@@ -0,0 +1,274 @@
+package repository
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/Anwarjondev/fast-food/internal/db"
+	"github.com/Anwarjondev/fast-food/internal/events"
+	"github.com/jmoiron/sqlx"
+	"github.com/jmoiron/sqlx/types"
+	"github.com/lib/pq"
+)
+
+var (
+	ErrWebhookNotFound         = errors.New("webhook not found")
+	ErrWebhookDeliveryNotFound = errors.New("webhook delivery not found")
+)
+
+// Webhook event types. Cancellations and completions are also status
+// changes; a webhook subscribed to both gets them once, as the more specific
+// type.
+const (
+	WebhookOrderCreated       = "order.created"
+	WebhookOrderStatusChanged = "order.status_changed"
+	WebhookOrderCanceled      = "order.canceled"
+	WebhookOrderCompleted     = "order.completed"
+)
+
+// Webhook delivery statuses
+const (
+	DeliveryPending   = "pending"
+	DeliveryDelivered = "delivered"
+	DeliveryFailed    = "failed"
+	DeliveryCanceled  = "canceled"
+)
+
+// Webhook is an endpoint order events are posted to. Secret is only filled
+// in when the webhook is created or its secret is changed.
+type Webhook struct {
+	ID         int            `json:"id" db:"id"`
+	URL        string         `json:"url" db:"url"`
+	Secret     string         `json:"secret,omitempty" db:"secret"`
+	EventTypes pq.StringArray `json:"event_types" db:"event_types" swaggertype:"array,string"`
+	IsActive   bool           `json:"is_active" db:"is_active"`
+	CreatedAt  time.Time      `json:"created_at" db:"created_at"`
+}
+
+// WebhookDelivery is one event queued for one webhook, with the outcome of
+// the last attempt to send it
+type WebhookDelivery struct {
+	ID             int            `json:"id" db:"id"`
+	WebhookID      int            `json:"webhook_id" db:"webhook_id"`
+	EventID        int            `json:"event_id" db:"event_id"`
+	EventType      string         `json:"event_type" db:"event_type"`
+	Payload        types.JSONText `json:"payload" db:"payload" swaggertype:"object"`
+	Status         string         `json:"status" db:"status"`
+	Attempts       int            `json:"attempts" db:"attempts"`
+	NextAttemptAt  time.Time      `json:"next_attempt_at" db:"next_attempt_at"`
+	LastAttemptAt  *time.Time     `json:"last_attempt_at" db:"last_attempt_at"`
+	ResponseStatus *int           `json:"response_status" db:"response_status"`
+	LastError      string         `json:"last_error" db:"last_error"`
+	CreatedAt      time.Time      `json:"created_at" db:"created_at"`
+	DeliveredAt    *time.Time     `json:"delivered_at" db:"delivered_at"`
+}
+
+// webhookColumns lists the webhooks columns scanned into Webhook, without the secret
+const webhookColumns = `id, url, '' AS secret, event_types, is_active, created_at`
+
+const webhookDeliveryColumns = `id, webhook_id, event_id, event_type, payload, status, attempts,
+	next_attempt_at, last_attempt_at, response_status, last_error, created_at, delivered_at`
+
+func GetWebhooks() ([]Webhook, error) {
+	webhooks := []Webhook{}
+	err := db.DB.Select(&webhooks, `SELECT `+webhookColumns+` FROM webhooks WHERE deleted_at IS NULL ORDER BY id`)
+	return webhooks, err
+}
+
+func GetWebhook(id int) (Webhook, error) {
+	var webhook Webhook
+	err := db.DB.Get(&webhook, `SELECT `+webhookColumns+` FROM webhooks WHERE id = $1 AND deleted_at IS NULL`, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return webhook, ErrWebhookNotFound
+	}
+	return webhook, err
+}
+
+func CreateWebhook(url, secret string, eventTypes []string, isActive bool) (Webhook, error) {
+	var webhook Webhook
+	err := db.DB.Get(&webhook, `
+		INSERT INTO webhooks (url, secret, event_types, is_active)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id, url, secret, event_types, is_active, created_at
+	`, url, secret, pq.Array(eventTypes), isActive)
+	return webhook, err
+}
+
+// UpdateWebhook changes a webhook. An empty secret keeps the current one.
+func UpdateWebhook(id int, url, secret string, eventTypes []string, isActive bool) (Webhook, error) {
+	var webhook Webhook
+	err := db.DB.Get(&webhook, `
+		UPDATE webhooks
+		SET url = $1, secret = COALESCE(NULLIF($2, ''), secret), event_types = $3, is_active = $4
+		WHERE id = $5 AND deleted_at IS NULL
+		RETURNING id, url, CASE WHEN $2 = '' THEN '' ELSE secret END AS secret, event_types, is_active, created_at
+	`, url, secret, pq.Array(eventTypes), isActive, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return webhook, ErrWebhookNotFound
+	}
+	return webhook, err
+}
+
+// DeleteWebhook soft-deletes a webhook so its delivery log is kept, and
+// cancels its pending deliveries
+func DeleteWebhook(id int) error {
+	tx, err := db.DB.Beginx()
+	if err != nil {
+		return err
+	}
+	res, err := tx.Exec(`UPDATE webhooks SET deleted_at = now() WHERE id = $1 AND deleted_at IS NULL`, id)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		tx.Rollback()
+		return ErrWebhookNotFound
+	}
+	_, err = tx.Exec(`UPDATE webhook_deliveries SET status = $1 WHERE webhook_id = $2 AND status = $3`, DeliveryCanceled, id, DeliveryPending)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// GetWebhookDeliveries returns the latest deliveries of a webhook, newest
+// first, optionally only those with status
+func GetWebhookDeliveries(webhookID int, status string, limit int) ([]WebhookDelivery, error) {
+	if _, err := GetWebhook(webhookID); err != nil {
+		return nil, err
+	}
+	deliveries := []WebhookDelivery{}
+	err := db.DB.Select(&deliveries, `
+		SELECT `+webhookDeliveryColumns+`
+		FROM webhook_deliveries
+		WHERE webhook_id = $1 AND ($2 = '' OR status = $2)
+		ORDER BY id DESC
+		LIMIT $3
+	`, webhookID, status, limit)
+	return deliveries, err
+}
+
+// RetryWebhookDelivery queues a delivery to be sent again right away,
+// whatever happened to it so far
+func RetryWebhookDelivery(webhookID, deliveryID int) (WebhookDelivery, error) {
+	if _, err := GetWebhook(webhookID); err != nil {
+		return WebhookDelivery{}, err
+	}
+	var delivery WebhookDelivery
+	err := db.DB.Get(&delivery, `
+		UPDATE webhook_deliveries
+		SET status = $1, next_attempt_at = now()
+		WHERE id = $2 AND webhook_id = $3
+		RETURNING `+webhookDeliveryColumns,
+		DeliveryPending, deliveryID, webhookID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return delivery, ErrWebhookDeliveryNotFound
+	}
+	return delivery, err
+}
+
+// webhookEventTypes returns the webhook event types an order event counts
+// as, most specific first
+func webhookEventTypes(e events.OrderEvent) []string {
+	switch {
+	case e.Type == events.OrderCreated:
+		return []string{WebhookOrderCreated}
+	case e.Status == StatusCanceled:
+		return []string{WebhookOrderCanceled, WebhookOrderStatusChanged}
+	case e.Status == StatusDelivered:
+		return []string{WebhookOrderCompleted, WebhookOrderStatusChanged}
+	}
+	return []string{WebhookOrderStatusChanged}
+}
+
+// queueWebhooks queues a delivery of every event to every active webhook
+// subscribed to it. It runs in the transaction that made the change, so an
+// event is queued if and only if the change is committed.
+func queueWebhooks(tx *sqlx.Tx, evs []events.OrderEvent) error {
+	for _, e := range evs {
+		data, err := json.Marshal(e)
+		if err != nil {
+			return err
+		}
+		_, err = tx.Exec(`
+			INSERT INTO webhook_deliveries (webhook_id, event_id, event_type, payload)
+			SELECT w.id, $1::int, t.event_type, jsonb_build_object('id', $1::int, 'type', t.event_type, 'data', $3::jsonb)
+			FROM webhooks w
+			CROSS JOIN LATERAL (
+				SELECT u.event_type FROM unnest($2::varchar[]) WITH ORDINALITY AS u(event_type, n)
+				WHERE cardinality(w.event_types) = 0 OR u.event_type = ANY(w.event_types)
+				ORDER BY u.n
+				LIMIT 1
+			) t
+			WHERE w.is_active AND w.deleted_at IS NULL
+		`, e.ID, pq.Array(webhookEventTypes(e)), string(data))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DueWebhookDelivery is a delivery claimed for sending
+type DueWebhookDelivery struct {
+	ID        int            `db:"id"`
+	URL       string         `db:"url"`
+	Secret    string         `db:"secret"`
+	EventType string         `db:"event_type"`
+	Payload   types.JSONText `db:"payload"`
+	Attempts  int            `db:"attempts"`
+}
+
+// ClaimWebhookDeliveries picks up to limit pending deliveries that are due
+// and pushes their next attempt lease into the future, so other replicas do
+// not send them at the same time
+func ClaimWebhookDeliveries(limit int, lease time.Duration) ([]DueWebhookDelivery, error) {
+	var due []DueWebhookDelivery
+	err := db.DB.Select(&due, `
+		UPDATE webhook_deliveries d
+		SET next_attempt_at = now() + make_interval(secs => $3)
+		FROM webhooks w
+		WHERE w.id = d.webhook_id AND d.id IN (
+			SELECT id FROM webhook_deliveries
+			WHERE status = $1 AND next_attempt_at <= now()
+			ORDER BY next_attempt_at
+			LIMIT $2
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING d.id, w.url, w.secret, d.event_type, d.payload, d.attempts
+	`, DeliveryPending, limit, lease.Seconds())
+	return due, err
+}
+
+// MarkWebhookDelivered records a successful attempt
+func MarkWebhookDelivered(id, responseStatus int) error {
+	_, err := db.DB.Exec(`
+		UPDATE webhook_deliveries
+		SET status = $1, attempts = attempts + 1, last_attempt_at = now(), delivered_at = now(),
+			response_status = $2, last_error = ''
+		WHERE id = $3
+	`, DeliveryDelivered, responseStatus, id)
+	return err
+}
+
+// MarkWebhookFailed records a failed attempt. responseStatus is 0 when no
+// response was received. The delivery is tried again after retryIn, or
+// given up when retryIn is 0.
+func MarkWebhookFailed(id, responseStatus int, lastError string, retryIn time.Duration) error {
+	status := DeliveryPending
+	if retryIn <= 0 {
+		status = DeliveryFailed
+	}
+	_, err := db.DB.Exec(`
+		UPDATE webhook_deliveries
+		SET status = $1, attempts = attempts + 1, last_attempt_at = now(),
+			next_attempt_at = now() + make_interval(secs => $2),
+			response_status = NULLIF($3, 0), last_error = $4
+		WHERE id = $5
+	`, status, retryIn.Seconds(), responseStatus, lastError, id)
+	return err
+}
@@ -0,0 +1,29 @@
+// Package response defines the error envelope shared by every handler and
+// middleware, so clients can rely on one shape for failures.
+package response
+
+import "github.com/gin-gonic/gin"
+
+// FieldError describes what is wrong with one field of the request
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// ErrorBody holds a machine-readable code, a human-readable message and
+// optional per-field details
+type ErrorBody struct {
+	Code    string       `json:"code"`
+	Message string       `json:"message"`
+	Details []FieldError `json:"details,omitempty"`
+}
+
+// Error is the body of every error response
+type Error struct {
+	Error ErrorBody `json:"error"`
+}
+
+// Abort writes an error response and stops the handler chain
+func Abort(c *gin.Context, status int, code, message string, details ...FieldError) {
+	c.AbortWithStatusJSON(status, Error{Error: ErrorBody{Code: code, Message: message, Details: details}})
+}
@@ -0,0 +1,186 @@
+// Package token signs and verifies the JWT access tokens used when the API
+// runs with AUTH_MODE=jwt.
+package token
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const issuer = "fast-food"
+
+type Claims struct {
+	jwt.RegisteredClaims
+	Roles     []string `json:"roles"`
+	SessionID int      `json:"sid"`
+}
+
+// UserID returns the user ID stored in the sub claim
+func (c *Claims) UserID() (int, error) {
+	return strconv.Atoi(c.Subject)
+}
+
+// Manager holds the keys of one algorithm. Tokens are signed with the key
+// named by the signing kid; every loaded key is accepted for verification,
+// so old keys can stay in the directory while tokens signed by them expire.
+type Manager struct {
+	method     jwt.SigningMethod
+	signingKID string
+	signingKey any
+	verifyKeys map[string]any
+}
+
+// NewManager loads the keys for alg (HS256, RS256 or EdDSA) from dir.
+// Each file is named after its kid: <kid>.key holds an HS256 secret,
+// <kid>.pem holds a PEM private key or, for verify-only keys, a public key.
+func NewManager(alg, dir, signingKID string) (*Manager, error) {
+	m := &Manager{signingKID: signingKID, verifyKeys: map[string]any{}}
+	switch alg {
+	case "HS256":
+		m.method = jwt.SigningMethodHS256
+	case "RS256":
+		m.method = jwt.SigningMethodRS256
+	case "EdDSA":
+		m.method = jwt.SigningMethodEdDSA
+	default:
+		return nil, fmt.Errorf("unsupported JWT algorithm %q", alg)
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		name := entry.Name()
+		ext := filepath.Ext(name)
+		kid := strings.TrimSuffix(name, ext)
+		data, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			return nil, err
+		}
+		switch {
+		case alg == "HS256" && ext == ".key":
+			secret := []byte(strings.TrimSpace(string(data)))
+			if len(secret) < 32 {
+				return nil, fmt.Errorf("JWT key %s: HS256 secret must be at least 32 bytes", kid)
+			}
+			m.verifyKeys[kid] = secret
+			if kid == signingKID {
+				m.signingKey = secret
+			}
+		case alg != "HS256" && ext == ".pem":
+			private, public, err := parsePEM(data)
+			if err != nil {
+				return nil, fmt.Errorf("JWT key %s: %w", kid, err)
+			}
+			if !m.matches(public) {
+				return nil, fmt.Errorf("JWT key %s is not an %s key", kid, alg)
+			}
+			m.verifyKeys[kid] = public
+			if kid == signingKID {
+				m.signingKey = private
+			}
+		}
+	}
+	if m.signingKey == nil {
+		return nil, fmt.Errorf("no %s private key with kid %q in %s", alg, signingKID, dir)
+	}
+	return m, nil
+}
+
+func (m *Manager) matches(public crypto.PublicKey) bool {
+	switch public.(type) {
+	case *rsa.PublicKey:
+		return m.method == jwt.SigningMethodRS256
+	case ed25519.PublicKey:
+		return m.method == jwt.SigningMethodEdDSA
+	}
+	return false
+}
+
+// parsePEM returns the private key (nil for a public-only file) and the public key
+func parsePEM(data []byte) (crypto.PrivateKey, crypto.PublicKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, nil, errors.New("no PEM block found")
+	}
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		key, err := x509.ParsePKCS1PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, nil, err
+		}
+		return key, &key.PublicKey, nil
+	case "PRIVATE KEY":
+		key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, nil, err
+		}
+		switch k := key.(type) {
+		case *rsa.PrivateKey:
+			return k, &k.PublicKey, nil
+		case ed25519.PrivateKey:
+			return k, k.Public(), nil
+		}
+		return nil, nil, fmt.Errorf("unsupported private key type %T", key)
+	case "PUBLIC KEY":
+		key, err := x509.ParsePKIXPublicKey(block.Bytes)
+		return nil, key, err
+	}
+	return nil, nil, fmt.Errorf("unsupported PEM block %q", block.Type)
+}
+
+// Sign issues an access token for a session
+func (m *Manager) Sign(userID int, roles []string, sessionID int, expiresAt time.Time) (string, error) {
+	now := time.Now()
+	claims := Claims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    issuer,
+			Subject:   strconv.Itoa(userID),
+			IssuedAt:  jwt.NewNumericDate(now),
+			NotBefore: jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(expiresAt),
+		},
+		Roles:     roles,
+		SessionID: sessionID,
+	}
+	t := jwt.NewWithClaims(m.method, claims)
+	t.Header["kid"] = m.signingKID
+	return t.SignedString(m.signingKey)
+}
+
+// Verify checks the signature, issuer and expiry of a token
+func (m *Manager) Verify(tokenString string) (*Claims, error) {
+	var claims Claims
+	_, err := jwt.ParseWithClaims(tokenString, &claims, func(t *jwt.Token) (any, error) {
+		kid, _ := t.Header["kid"].(string)
+		key, ok := m.verifyKeys[kid]
+		if !ok {
+			return nil, fmt.Errorf("unknown kid %q", kid)
+		}
+		return key, nil
+	},
+		jwt.WithValidMethods([]string{m.method.Alg()}),
+		jwt.WithIssuer(issuer),
+		jwt.WithExpirationRequired(),
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &claims, nil
+}
@@ -1,12 +1,16 @@
 package utils
 
 import (
-	"math/rand"
-	"time"
+	"crypto/rand"
+	"fmt"
+	"math/big"
 )
 
-
-func GenerateCode() int {
-	rand.Seed(time.Now().UnixNano())
-	return rand.Intn(9000) + 1000
-}
\ No newline at end of file
+// GenerateCode returns a random 6-digit verification code
+func GenerateCode() (string, error) {
+	n, err := rand.Int(rand.Reader, big.NewInt(1000000))
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%06d", n.Int64()), nil
+}
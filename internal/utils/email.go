@@ -7,7 +7,7 @@ import (
 	"strings"
 )
 
-func SendEmailCode(to string, code int, smtpHost, smtpPort, email, password string) error {
+func SendEmail(to, subject, text string, smtpHost, smtpPort, email, password string) error {
 	// Trim any trailing periods from the email address
 	to = strings.TrimRight(to, ".")
 
@@ -16,7 +16,7 @@ func SendEmailCode(to string, code int, smtpHost, smtpPort, email, password stri
 	log.Printf("SMTP Host: %s, Port: %s", smtpHost, smtpPort)
 	log.Printf("From email: %s", email)
 
-	body := fmt.Sprintf("To: %s\r\nSubject: Confirmation Code\r\n\r\nYour confirmation code is %d", to, code)
+	body := fmt.Sprintf("To: %s\r\nSubject: %s\r\n\r\n%s", to, subject, text)
 	auth := smtp.PlainAuth("", email, password, smtpHost)
 
 	// Construct the full SMTP address
@@ -32,3 +32,13 @@ func SendEmailCode(to string, code int, smtpHost, smtpPort, email, password stri
 	log.Printf("Email sent successfully to %s", to)
 	return nil
 }
+
+func SendEmailCode(to string, code string, smtpHost, smtpPort, email, password string) error {
+	return SendEmail(to, "Confirmation Code", fmt.Sprintf("Your confirmation code is %s", code), smtpHost, smtpPort, email, password)
+}
+
+func SendPasswordChangedEmail(to string, smtpHost, smtpPort, email, password string) error {
+	text := "Your password was changed and you were logged out on all devices.\r\n" +
+		"If you did not do this, reset your password immediately."
+	return SendEmail(to, "Your password was changed", text, smtpHost, smtpPort, email, password)
+}
@@ -0,0 +1,32 @@
+package utils
+
+import (
+	"crypto/subtle"
+	"strings"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+func HashPassword(password string, cost int) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), cost)
+	return string(hash), err
+}
+
+func isBcryptHash(stored string) bool {
+	return strings.HasPrefix(stored, "$2a$") || strings.HasPrefix(stored, "$2b$") || strings.HasPrefix(stored, "$2y$")
+}
+
+// CheckPassword compares password with the stored value, which is either a
+// bcrypt hash or a legacy plaintext password. needsRehash reports that the
+// password matched but should be stored again with HashPassword at cost.
+func CheckPassword(stored, password string, cost int) (ok, needsRehash bool) {
+	if !isBcryptHash(stored) {
+		ok = subtle.ConstantTimeCompare([]byte(stored), []byte(password)) == 1
+		return ok, ok
+	}
+	if bcrypt.CompareHashAndPassword([]byte(stored), []byte(password)) != nil {
+		return false, false
+	}
+	storedCost, err := bcrypt.Cost([]byte(stored))
+	return true, err != nil || storedCost != cost
+}
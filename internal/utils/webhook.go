@@ -0,0 +1,44 @@
+package utils
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SignWebhook returns the X-Webhook-Signature header for a webhook body sent
+// at timestamp (Unix seconds): "t=<timestamp>,v1=<hex HMAC-SHA256 of
+// "<timestamp>.<body>" keyed with secret>". Receivers recompute the HMAC and
+// reject old timestamps to stop replays.
+func SignWebhook(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	fmt.Fprintf(mac, "%d.", timestamp)
+	mac.Write(body)
+	return fmt.Sprintf("t=%d,v1=%s", timestamp, hex.EncodeToString(mac.Sum(nil)))
+}
+
+// VerifyWebhook checks a signature made by SignWebhook and rejects
+// timestamps more than tolerance away from now
+func VerifyWebhook(secret, signature string, body []byte, tolerance time.Duration) bool {
+	var timestamp int64
+	var sum string
+	for _, part := range strings.Split(signature, ",") {
+		key, value, _ := strings.Cut(part, "=")
+		switch key {
+		case "t":
+			timestamp, _ = strconv.ParseInt(value, 10, 64)
+		case "v1":
+			sum = value
+		}
+	}
+	age := time.Since(time.Unix(timestamp, 0))
+	if timestamp == 0 || age > tolerance || age < -tolerance {
+		return false
+	}
+	expected := SignWebhook(secret, timestamp, body)
+	return hmac.Equal([]byte(expected), []byte(fmt.Sprintf("t=%d,v1=%s", timestamp, sum)))
+}
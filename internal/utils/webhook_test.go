@@ -0,0 +1,57 @@
+package utils
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSignWebhook(t *testing.T) {
+	body := []byte(`{"event":"order.status_changed"}`)
+	tests := []struct {
+		secret    string
+		timestamp int64
+		body      []byte
+		want      string
+	}{
+		{"whsec_test", 1700000000, body, "t=1700000000,v1=a30631e98ae16ff2a409346ceb53c7e864989c3a20e951ef435f06311d46277e"},
+		{"other", 1700000000, body, "t=1700000000,v1=43ca4542ddcf772976a980357cea529d88c55c5b822fd05d03bdacc2f340ff5c"},
+	}
+	for _, tt := range tests {
+		if got := SignWebhook(tt.secret, tt.timestamp, tt.body); got != tt.want {
+			t.Errorf("SignWebhook(%q, %d) = %q, want %q", tt.secret, tt.timestamp, got, tt.want)
+		}
+	}
+	if SignWebhook("whsec_test", 1700000001, body) == SignWebhook("whsec_test", 1700000000, body) {
+		t.Error("signature does not cover the timestamp")
+	}
+}
+
+func TestVerifyWebhook(t *testing.T) {
+	body := []byte(`{"event":"order.status_changed"}`)
+	now := time.Now().Unix()
+	signature := SignWebhook("whsec_test", now, body)
+	_, sum, _ := strings.Cut(signature, ",")
+	tests := []struct {
+		name      string
+		signature string
+		body      []byte
+		want      bool
+	}{
+		{"round trip", signature, body, true},
+		{"within tolerance", SignWebhook("whsec_test", now-240, body), body, true},
+		{"tampered body", signature, []byte(`{"event":"order.canceled"}`), false},
+		{"wrong secret", SignWebhook("other", now, body), body, false},
+		{"tampered timestamp", fmt.Sprintf("t=%d,%s", now-1, sum), body, false},
+		{"stale timestamp", SignWebhook("whsec_test", now-600, body), body, false},
+		{"future timestamp", SignWebhook("whsec_test", now+600, body), body, false},
+		{"no timestamp", sum, body, false},
+		{"empty", "", body, false},
+	}
+	for _, tt := range tests {
+		if got := VerifyWebhook("whsec_test", tt.signature, tt.body, 5*time.Minute); got != tt.want {
+			t.Errorf("%s: VerifyWebhook = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
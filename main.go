@@ -1,12 +1,19 @@
 package main
 
 import (
+	"log"
+	"os"
+
 	"github.com/Anwarjondev/fast-food/config"
 	_ "github.com/Anwarjondev/fast-food/docs" // Import with underscore for initialization
 	"github.com/Anwarjondev/fast-food/internal/background"
 	"github.com/Anwarjondev/fast-food/internal/db"
+	"github.com/Anwarjondev/fast-food/internal/events"
 	"github.com/Anwarjondev/fast-food/internal/handlers"
 	"github.com/Anwarjondev/fast-food/internal/middleware"
+	"github.com/Anwarjondev/fast-food/internal/payment"
+	"github.com/Anwarjondev/fast-food/internal/repository"
+	"github.com/Anwarjondev/fast-food/internal/token"
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
 	swaggerFiles "github.com/swaggo/files"     // swagger embed files
@@ -31,12 +38,46 @@ import (
 // @securityDefinitions.apikey BearerAuth
 // @in header
 // @name Authorization
-// @description Type "Bearer" followed by a space and JWT token.
+// @description Type "Bearer" followed by a space and the access token (opaque or JWT, depending on AUTH_MODE).
 func main() {
-	background.AutoCompleteOrders()
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		runMigrate(config.LoadDB(), os.Args[2:])
+		return
+	}
 	cfg := config.Load()
+
+	var jwtManager *token.Manager
+	if cfg.AuthMode == "jwt" {
+		var err error
+		jwtManager, err = token.NewManager(cfg.JWTAlgorithm, cfg.JWTKeysDir, cfg.JWTSigningKID)
+		if err != nil {
+			log.Fatalln("Error loading JWT keys: ", err)
+		}
+	}
+
+	if cfg.AutoCompleteOrders {
+		background.AutoCompleteOrders(cfg.AutoCompleteInterval, cfg.AutoCompleteAfter)
+	}
 	db.Connect(cfg.DBNS)
+	if cfg.EventsBroker == "postgres" {
+		broker, err := events.NewPostgresBroker(cfg.DBNS, db.DB)
+		if err != nil {
+			log.Fatalln("Error listening for order events: ", err)
+		}
+		events.SetBroker(broker)
+	}
+	background.DeliverWebhooks(cfg.WebhookDeliveryInterval)
+	for _, method := range cfg.PaymentMethods {
+		switch method {
+		case "cash":
+			payment.Register(payment.CashProvider{})
+		case "card":
+			payment.Register(payment.FakeCardProvider{Secret: cfg.FakeCardSecret})
+		}
+	}
+	background.ProcessPayments(cfg.PaymentInterval, cfg.PaymentTimeout)
 	handlers.SetConfig(cfg)
+	handlers.SetTokenManager(jwtManager)
 	r := gin.Default()
 	r.Use(gin.Logger(), gin.Recovery())
 
@@ -52,7 +93,7 @@ func main() {
 	// Swagger documentation
 	r.GET("/docs/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
 
-	auth := middleware.AuthMiddleware()
+	auth := middleware.AuthMiddleware(jwtManager)
 
 	// Auth routes
 	// @Summary Register a new user
@@ -76,7 +117,7 @@ func main() {
 	r.POST("/resend-code", handlers.ResendCode)
 
 	// @Summary Confirm user registration
-	// @Description Confirm user registration with code
+	// @Description Confirm user registration with the email and the code sent to it
 	// @Tags auth
 	// @Accept json
 	// @Produce json
@@ -95,10 +136,21 @@ func main() {
 	// @Router /login [post]
 	r.POST("/login", handlers.Login)
 
+	// @Summary Refresh tokens
+	// @Description Exchange a refresh token for a new access and refresh token
+	// @Tags auth
+	// @Accept json
+	// @Produce json
+	// @Param request body handlers.RefreshTokenRequest true "Refresh token"
+	// @Success 200 {object} repository.TokenPair
+	// @Router /token/refresh [post]
+	r.POST("/token/refresh", handlers.RefreshToken)
+
 	// @Summary User logout
-	// @Description Logout user and invalidate token
+	// @Description Logout the current session, or every session of the user with all=true
 	// @Tags auth
 	// @Security BearerAuth
+	// @Param all query bool false "Log out on every device"
 	// @Success 200 {object} handlers.Response
 	// @Router /logout [post]
 	r.POST("/logout", auth, handlers.Logout)
@@ -114,7 +166,7 @@ func main() {
 	r.POST("/forgot-password", handlers.ForgotPassword)
 
 	// @Summary Reset password
-	// @Description Reset password with code
+	// @Description Reset password with the code sent by forgot-password. Logs the user out on every device.
 	// @Tags auth
 	// @Accept json
 	// @Produce json
@@ -165,6 +217,17 @@ func main() {
 	// @Router /orders [post]
 	r.POST("/orders", auth, handlers.CreateOrder)
 
+	// Payment routes
+	// @Summary Payment provider webhook
+	// @Description Receive a payment update from a payment provider
+	// @Tags payments
+	// @Accept json
+	// @Produce json
+	// @Param method path string true "Payment method"
+	// @Success 200 {object} handlers.Response
+	// @Router /payments/{method}/webhook [post]
+	r.POST("/payments/:method/webhook", handlers.PaymentWebhook)
+
 	// @Summary Get active orders
 	// @Description Get list of active orders
 	// @Tags orders
@@ -192,6 +255,47 @@ func main() {
 	// @Router /orders/all [get]
 	r.GET("/orders/all", auth, handlers.GetOrderByStatus("all"))
 
+	// @Summary Stream order events
+	// @Description Server-Sent Events stream of order events: the user's own orders for customers, every order for staff
+	// @Tags orders
+	// @Security BearerAuth
+	// @Produce text/event-stream
+	// @Param last_event_id query int false "Replay events after this ID"
+	// @Success 200 {object} events.OrderEvent
+	// @Router /orders/stream [get]
+	r.GET("/orders/stream", middleware.QueryToken(), auth, handlers.StreamOrders)
+
+	// @Summary Get order
+	// @Description Get an order with its lines. Customers can only see their own orders; staff can see any.
+	// @Tags orders
+	// @Security BearerAuth
+	// @Produce json
+	// @Param order_id path int true "Order ID"
+	// @Success 200 {object} repository.OrderWithLines
+	// @Router /orders/{order_id} [get]
+	r.GET("/orders/:order_id", auth, handlers.GetOrder)
+
+	// @Summary Get order timeline
+	// @Description Get every status change of an order, oldest first, with who made it and why
+	// @Tags orders
+	// @Security BearerAuth
+	// @Produce json
+	// @Param order_id path int true "Order ID"
+	// @Success 200 {object} []repository.OrderStatusChange
+	// @Router /orders/{order_id}/history [get]
+	r.GET("/orders/:order_id/history", auth, handlers.GetOrderHistory)
+
+	// @Summary Stream events of an order
+	// @Description Server-Sent Events stream of the status changes of one order
+	// @Tags orders
+	// @Security BearerAuth
+	// @Produce text/event-stream
+	// @Param order_id path int true "Order ID"
+	// @Param last_event_id query int false "Replay events after this ID"
+	// @Success 200 {object} events.OrderEvent
+	// @Router /orders/{order_id}/stream [get]
+	r.GET("/orders/:order_id/stream", middleware.QueryToken(), auth, handlers.StreamOrder)
+
 	// @Summary Cancel order
 	// @Description Cancel an existing order
 	// @Tags orders
@@ -202,5 +306,552 @@ func main() {
 	// @Router /orders/{order_id} [put]
 	r.PUT("/orders/:order_id", auth, handlers.CancelOrder)
 
+	// Cart routes
+	// @Summary Get cart
+	// @Description Get the user's cart with current prices and stock
+	// @Tags cart
+	// @Security BearerAuth
+	// @Produce json
+	// @Success 200 {object} repository.Cart
+	// @Router /cart [get]
+	r.GET("/cart", auth, handlers.GetCart)
+
+	// @Summary Clear cart
+	// @Description Remove every food from the cart
+	// @Tags cart
+	// @Security BearerAuth
+	// @Produce json
+	// @Success 200 {object} repository.Cart
+	// @Router /cart [delete]
+	r.DELETE("/cart", auth, handlers.ClearCart)
+
+	// @Summary Add to cart
+	// @Description Add a food to the cart
+	// @Tags cart
+	// @Security BearerAuth
+	// @Accept json
+	// @Produce json
+	// @Param request body handlers.CartItemRequest true "Food and count"
+	// @Success 200 {object} repository.Cart
+	// @Router /cart/items [post]
+	r.POST("/cart/items", auth, handlers.AddCartItem)
+
+	// @Summary Update cart item
+	// @Description Set how many of a food the cart holds
+	// @Tags cart
+	// @Security BearerAuth
+	// @Accept json
+	// @Produce json
+	// @Param food_id path int true "Food ID"
+	// @Param request body handlers.CartCountRequest true "Count"
+	// @Success 200 {object} repository.Cart
+	// @Router /cart/items/{food_id} [put]
+	r.PUT("/cart/items/:food_id", auth, handlers.UpdateCartItem)
+
+	// @Summary Remove from cart
+	// @Description Remove a food from the cart
+	// @Tags cart
+	// @Security BearerAuth
+	// @Produce json
+	// @Param food_id path int true "Food ID"
+	// @Success 200 {object} repository.Cart
+	// @Router /cart/items/{food_id} [delete]
+	r.DELETE("/cart/items/:food_id", auth, handlers.RemoveCartItem)
+
+	// @Summary Checkout cart
+	// @Description Place an order for everything in the cart and empty it
+	// @Tags cart
+	// @Security BearerAuth
+	// @Produce json
+	// @Success 200 {object} handlers.Response
+	// @Router /cart/checkout [post]
+	r.POST("/cart/checkout", auth, handlers.Checkout)
+
+	// Address routes
+	// @Summary List addresses
+	// @Description List the user's addresses
+	// @Tags addresses
+	// @Security BearerAuth
+	// @Produce json
+	// @Success 200 {object} []repository.Address
+	// @Router /addresses [get]
+	r.GET("/addresses", auth, handlers.GetAddresses)
+
+	// @Summary Create address
+	// @Description Add an address to the user's address book
+	// @Tags addresses
+	// @Security BearerAuth
+	// @Accept json
+	// @Produce json
+	// @Param request body handlers.AddressRequest true "Address"
+	// @Success 201 {object} repository.Address
+	// @Router /addresses [post]
+	r.POST("/addresses", auth, handlers.CreateAddress)
+
+	// @Summary Get address
+	// @Description Get one of the user's addresses
+	// @Tags addresses
+	// @Security BearerAuth
+	// @Produce json
+	// @Param id path int true "Address ID"
+	// @Success 200 {object} repository.Address
+	// @Router /addresses/{id} [get]
+	r.GET("/addresses/:id", auth, handlers.GetAddress)
+
+	// @Summary Update address
+	// @Description Change one of the user's addresses
+	// @Tags addresses
+	// @Security BearerAuth
+	// @Accept json
+	// @Produce json
+	// @Param id path int true "Address ID"
+	// @Param request body handlers.AddressRequest true "Address"
+	// @Success 200 {object} repository.Address
+	// @Router /addresses/{id} [put]
+	r.PUT("/addresses/:id", auth, handlers.UpdateAddress)
+
+	// @Summary Delete address
+	// @Description Remove one of the user's addresses
+	// @Tags addresses
+	// @Security BearerAuth
+	// @Produce json
+	// @Param id path int true "Address ID"
+	// @Success 200 {object} handlers.Response
+	// @Router /addresses/{id} [delete]
+	r.DELETE("/addresses/:id", auth, handlers.DeleteAddress)
+
+	// @Summary Set default address
+	// @Description Make one of the user's addresses the default
+	// @Tags addresses
+	// @Security BearerAuth
+	// @Produce json
+	// @Param id path int true "Address ID"
+	// @Success 200 {object} repository.Address
+	// @Router /addresses/{id}/default [post]
+	r.POST("/addresses/:id/default", auth, handlers.SetDefaultAddress)
+
+	// Kitchen routes
+	kitchen := r.Group("/kitchen", auth, middleware.RequireRole(repository.RoleKitchen, repository.RoleCashier, repository.RoleAdmin))
+
+	// @Summary Get kitchen queue
+	// @Description List orders the kitchen still has to work on, oldest first, with their lines (staff only)
+	// @Tags kitchen
+	// @Security BearerAuth
+	// @Produce json
+	// @Param status query string false "Only orders in this status (placed, accepted, preparing, ready)"
+	// @Success 200 {object} []repository.OrderWithLines
+	// @Router /kitchen/orders [get]
+	kitchen.GET("/orders", handlers.GetKitchenQueue)
+
+	// @Summary Accept order
+	// @Description Accept a placed order (staff only)
+	// @Tags kitchen
+	// @Security BearerAuth
+	// @Produce json
+	// @Param order_id path int true "Order ID"
+	// @Success 200 {object} repository.Order
+	// @Router /kitchen/orders/{order_id}/accept [post]
+	kitchen.POST("/orders/:order_id/accept", handlers.AdvanceOrder(repository.StatusAccepted))
+
+	// @Summary Start preparing order
+	// @Description Mark an accepted order as being prepared (staff only)
+	// @Tags kitchen
+	// @Security BearerAuth
+	// @Produce json
+	// @Param order_id path int true "Order ID"
+	// @Success 200 {object} repository.Order
+	// @Router /kitchen/orders/{order_id}/prepare [post]
+	kitchen.POST("/orders/:order_id/prepare", handlers.AdvanceOrder(repository.StatusPreparing))
+
+	// @Summary Mark order ready
+	// @Description Mark an order being prepared as ready (staff only)
+	// @Tags kitchen
+	// @Security BearerAuth
+	// @Produce json
+	// @Param order_id path int true "Order ID"
+	// @Success 200 {object} repository.Order
+	// @Router /kitchen/orders/{order_id}/ready [post]
+	kitchen.POST("/orders/:order_id/ready", handlers.AdvanceOrder(repository.StatusReady))
+
+	// @Summary Complete order
+	// @Description Mark a ready pickup order as handed over to the customer (staff only)
+	// @Tags kitchen
+	// @Security BearerAuth
+	// @Produce json
+	// @Param order_id path int true "Order ID"
+	// @Success 200 {object} repository.Order
+	// @Router /kitchen/orders/{order_id}/complete [post]
+	kitchen.POST("/orders/:order_id/complete", handlers.HandOverOrder(repository.FulfillmentPickup, repository.StatusDelivered))
+
+	// @Summary Reject order
+	// @Description Reject an order that has not been made yet and put its foods back into stock (staff only)
+	// @Tags kitchen
+	// @Security BearerAuth
+	// @Accept json
+	// @Produce json
+	// @Param order_id path int true "Order ID"
+	// @Param request body handlers.RejectOrderRequest true "Why the order is rejected"
+	// @Success 200 {object} repository.Order
+	// @Router /kitchen/orders/{order_id}/reject [post]
+	kitchen.POST("/orders/:order_id/reject", handlers.RejectOrder)
+
+	// Courier routes
+	courier := r.Group("/courier", auth, middleware.RequireRole(repository.RoleCourier, repository.RoleAdmin))
+
+	// @Summary Pick up order
+	// @Description Take a ready delivery order out for delivery (couriers only)
+	// @Tags courier
+	// @Security BearerAuth
+	// @Produce json
+	// @Param order_id path int true "Order ID"
+	// @Success 200 {object} repository.Order
+	// @Router /courier/orders/{order_id}/pickup [post]
+	courier.POST("/orders/:order_id/pickup", handlers.HandOverOrder(repository.FulfillmentDelivery, repository.StatusOutForDelivery))
+
+	// @Summary Deliver order
+	// @Description Mark a delivery order that is out for delivery as delivered (couriers only)
+	// @Tags courier
+	// @Security BearerAuth
+	// @Produce json
+	// @Param order_id path int true "Order ID"
+	// @Success 200 {object} repository.Order
+	// @Router /courier/orders/{order_id}/deliver [post]
+	courier.POST("/orders/:order_id/deliver", handlers.HandOverOrder(repository.FulfillmentDelivery, repository.StatusDelivered))
+
+	// @Summary Staff dashboard socket
+	// @Description WebSocket pushing new orders and status changes to staff screens
+	// @Tags kitchen
+	// @Security BearerAuth
+	// @Param last_event_id query int false "Replay events after this ID"
+	// @Success 101 {object} handlers.SocketMessage
+	// @Router /staff/ws [get]
+	r.GET("/staff/ws", middleware.QueryToken(), auth,
+		middleware.RequireRole(repository.RoleKitchen, repository.RoleCashier, repository.RoleCourier, repository.RoleAdmin),
+		handlers.StaffSocket)
+
+	// Admin routes
+	admin := r.Group("/admin", auth, middleware.RequireRole(repository.RoleAdmin))
+
+	// @Summary Create category
+	// @Description Create a new food category (admin only)
+	// @Tags admin
+	// @Security BearerAuth
+	// @Accept json
+	// @Produce json
+	// @Param request body handlers.CategoryRequest true "Category"
+	// @Success 201 {object} repository.Category
+	// @Router /admin/categories [post]
+	admin.POST("/categories", handlers.CreateCategory)
+
+	// @Summary Reorder categories
+	// @Description Set the display order of categories (admin only)
+	// @Tags admin
+	// @Security BearerAuth
+	// @Accept json
+	// @Produce json
+	// @Param request body handlers.ReorderRequest true "Category IDs in display order"
+	// @Success 200 {object} handlers.Response
+	// @Router /admin/categories/order [put]
+	admin.PUT("/categories/order", handlers.ReorderCategories)
+
+	// @Summary Update category
+	// @Description Rename a food category (admin only)
+	// @Tags admin
+	// @Security BearerAuth
+	// @Accept json
+	// @Produce json
+	// @Param id path int true "Category ID"
+	// @Param request body handlers.CategoryRequest true "Category"
+	// @Success 200 {object} repository.Category
+	// @Router /admin/categories/{id} [put]
+	admin.PUT("/categories/:id", handlers.UpdateCategory)
+
+	// @Summary Delete category
+	// @Description Soft-delete an empty food category (admin only)
+	// @Tags admin
+	// @Security BearerAuth
+	// @Produce json
+	// @Param id path int true "Category ID"
+	// @Success 200 {object} handlers.Response
+	// @Router /admin/categories/{id} [delete]
+	admin.DELETE("/categories/:id", handlers.DeleteCategory)
+
+	// @Summary Reorder foods
+	// @Description Set the display order of foods in a category (admin only)
+	// @Tags admin
+	// @Security BearerAuth
+	// @Accept json
+	// @Produce json
+	// @Param id path int true "Category ID"
+	// @Param request body handlers.ReorderRequest true "Food IDs in display order"
+	// @Success 200 {object} handlers.Response
+	// @Router /admin/categories/{id}/foods/order [put]
+	admin.PUT("/categories/:id/foods/order", handlers.ReorderFoods)
+
+	// @Summary Create food
+	// @Description Add a new food to a category (admin only)
+	// @Tags admin
+	// @Security BearerAuth
+	// @Accept json
+	// @Produce json
+	// @Param request body handlers.FoodRequest true "Food"
+	// @Success 201 {object} repository.Food
+	// @Router /admin/foods [post]
+	admin.POST("/foods", handlers.CreateFood)
+
+	// @Summary Update food
+	// @Description Update name, price, image, stock or category of a food (admin only)
+	// @Tags admin
+	// @Security BearerAuth
+	// @Accept json
+	// @Produce json
+	// @Param id path int true "Food ID"
+	// @Param request body handlers.FoodRequest true "Food"
+	// @Success 200 {object} repository.Food
+	// @Router /admin/foods/{id} [put]
+	admin.PUT("/foods/:id", handlers.UpdateFood)
+
+	// @Summary Delete food
+	// @Description Soft-delete a food so it no longer appears on the menu (admin only)
+	// @Tags admin
+	// @Security BearerAuth
+	// @Produce json
+	// @Param id path int true "Food ID"
+	// @Success 200 {object} handlers.Response
+	// @Router /admin/foods/{id} [delete]
+	admin.DELETE("/foods/:id", handlers.DeleteFood)
+
+	// @Summary Get user roles
+	// @Description Get the roles granted to a user (admin only)
+	// @Tags admin
+	// @Security BearerAuth
+	// @Produce json
+	// @Param id path int true "User ID"
+	// @Success 200 {object} handlers.Response
+	// @Router /admin/users/{id}/roles [get]
+	admin.GET("/users/:id/roles", handlers.GetUserRoles)
+
+	// @Summary Grant role
+	// @Description Grant a role to a user (admin only)
+	// @Tags admin
+	// @Security BearerAuth
+	// @Accept json
+	// @Produce json
+	// @Param id path int true "User ID"
+	// @Param request body handlers.RoleRequest true "Role"
+	// @Success 200 {object} handlers.Response
+	// @Router /admin/users/{id}/roles [post]
+	admin.POST("/users/:id/roles", handlers.GrantRole)
+
+	// @Summary Revoke role
+	// @Description Revoke a role from a user (admin only)
+	// @Tags admin
+	// @Security BearerAuth
+	// @Produce json
+	// @Param id path int true "User ID"
+	// @Param role path string true "Role"
+	// @Success 200 {object} handlers.Response
+	// @Router /admin/users/{id}/roles/{role} [delete]
+	admin.DELETE("/users/:id/roles/:role", handlers.RevokeRole)
+
+	// @Summary List promotions
+	// @Description List promo codes (admin only)
+	// @Tags admin
+	// @Security BearerAuth
+	// @Produce json
+	// @Success 200 {object} []repository.Promotion
+	// @Router /admin/promotions [get]
+	admin.GET("/promotions", handlers.GetPromotions)
+
+	// @Summary Create promotion
+	// @Description Create a promo code (admin only)
+	// @Tags admin
+	// @Security BearerAuth
+	// @Accept json
+	// @Produce json
+	// @Param request body handlers.PromotionRequest true "Promotion"
+	// @Success 201 {object} repository.Promotion
+	// @Router /admin/promotions [post]
+	admin.POST("/promotions", handlers.CreatePromotion)
+
+	// @Summary Get promotion
+	// @Description Get a promo code (admin only)
+	// @Tags admin
+	// @Security BearerAuth
+	// @Produce json
+	// @Param id path int true "Promotion ID"
+	// @Success 200 {object} repository.Promotion
+	// @Router /admin/promotions/{id} [get]
+	admin.GET("/promotions/:id", handlers.GetPromotion)
+
+	// @Summary Update promotion
+	// @Description Update a promo code (admin only)
+	// @Tags admin
+	// @Security BearerAuth
+	// @Accept json
+	// @Produce json
+	// @Param id path int true "Promotion ID"
+	// @Param request body handlers.PromotionRequest true "Promotion"
+	// @Success 200 {object} repository.Promotion
+	// @Router /admin/promotions/{id} [put]
+	admin.PUT("/promotions/:id", handlers.UpdatePromotion)
+
+	// @Summary Delete promotion
+	// @Description Soft-delete a promo code (admin only)
+	// @Tags admin
+	// @Security BearerAuth
+	// @Produce json
+	// @Param id path int true "Promotion ID"
+	// @Success 200 {object} handlers.Response
+	// @Router /admin/promotions/{id} [delete]
+	admin.DELETE("/promotions/:id", handlers.DeletePromotion)
+
+	// @Summary List webhooks
+	// @Description List webhook subscriptions without their secrets (admin only)
+	// @Tags admin
+	// @Security BearerAuth
+	// @Produce json
+	// @Success 200 {object} []repository.Webhook
+	// @Router /admin/webhooks [get]
+	admin.GET("/webhooks", handlers.GetWebhooks)
+
+	// @Summary Create webhook
+	// @Description Subscribe a URL to order events (admin only)
+	// @Tags admin
+	// @Security BearerAuth
+	// @Accept json
+	// @Produce json
+	// @Param request body handlers.WebhookRequest true "Webhook"
+	// @Success 201 {object} repository.Webhook
+	// @Router /admin/webhooks [post]
+	admin.POST("/webhooks", handlers.CreateWebhook)
+
+	// @Summary Get webhook
+	// @Description Get a webhook subscription without its secret (admin only)
+	// @Tags admin
+	// @Security BearerAuth
+	// @Produce json
+	// @Param id path int true "Webhook ID"
+	// @Success 200 {object} repository.Webhook
+	// @Router /admin/webhooks/{id} [get]
+	admin.GET("/webhooks/:id", handlers.GetWebhook)
+
+	// @Summary Update webhook
+	// @Description Change a webhook (admin only)
+	// @Tags admin
+	// @Security BearerAuth
+	// @Accept json
+	// @Produce json
+	// @Param id path int true "Webhook ID"
+	// @Param request body handlers.WebhookRequest true "Webhook"
+	// @Success 200 {object} repository.Webhook
+	// @Router /admin/webhooks/{id} [put]
+	admin.PUT("/webhooks/:id", handlers.UpdateWebhook)
+
+	// @Summary Delete webhook
+	// @Description Delete a webhook and cancel its pending deliveries (admin only)
+	// @Tags admin
+	// @Security BearerAuth
+	// @Produce json
+	// @Param id path int true "Webhook ID"
+	// @Success 200 {object} handlers.Response
+	// @Router /admin/webhooks/{id} [delete]
+	admin.DELETE("/webhooks/:id", handlers.DeleteWebhook)
+
+	// @Summary Webhook delivery log
+	// @Description List the latest deliveries of a webhook (admin only)
+	// @Tags admin
+	// @Security BearerAuth
+	// @Produce json
+	// @Param id path int true "Webhook ID"
+	// @Success 200 {object} []repository.WebhookDelivery
+	// @Router /admin/webhooks/{id}/deliveries [get]
+	admin.GET("/webhooks/:id/deliveries", handlers.GetWebhookDeliveries)
+
+	// @Summary Retry webhook delivery
+	// @Description Queue a delivery to be sent again right away (admin only)
+	// @Tags admin
+	// @Security BearerAuth
+	// @Produce json
+	// @Param id path int true "Webhook ID"
+	// @Param delivery_id path int true "Delivery ID"
+	// @Success 200 {object} repository.WebhookDelivery
+	// @Router /admin/webhooks/{id}/deliveries/{delivery_id}/retry [post]
+	admin.POST("/webhooks/:id/deliveries/:delivery_id/retry", handlers.RetryWebhookDelivery)
+
+	// @Summary Sales report
+	// @Description Totals of the orders placed per day and currency (admin only)
+	// @Tags admin
+	// @Security BearerAuth
+	// @Produce json
+	// @Param from query string false "First day, YYYY-MM-DD"
+	// @Param to query string false "Last day, YYYY-MM-DD"
+	// @Success 200 {object} []repository.SalesDay
+	// @Router /admin/reports/sales [get]
+	admin.GET("/reports/sales", handlers.GetSalesReport)
+
+	// @Summary Refund report
+	// @Description Refunds issued in a range of days (admin only)
+	// @Tags admin
+	// @Security BearerAuth
+	// @Produce json
+	// @Param from query string false "First day, YYYY-MM-DD"
+	// @Param to query string false "Last day, YYYY-MM-DD"
+	// @Success 200 {object} []repository.Refund
+	// @Router /admin/reports/refunds [get]
+	admin.GET("/reports/refunds", handlers.GetRefundReport)
+
+	// @Summary List delivery zones
+	// @Description List delivery zones (admin only)
+	// @Tags admin
+	// @Security BearerAuth
+	// @Produce json
+	// @Success 200 {object} []repository.DeliveryZone
+	// @Router /admin/delivery-zones [get]
+	admin.GET("/delivery-zones", handlers.GetDeliveryZones)
+
+	// @Summary Create delivery zone
+	// @Description Add a delivery zone (admin only)
+	// @Tags admin
+	// @Security BearerAuth
+	// @Accept json
+	// @Produce json
+	// @Param request body handlers.DeliveryZoneRequest true "Delivery zone"
+	// @Success 201 {object} repository.DeliveryZone
+	// @Router /admin/delivery-zones [post]
+	admin.POST("/delivery-zones", handlers.CreateDeliveryZone)
+
+	// @Summary Get delivery zone
+	// @Description Get a delivery zone (admin only)
+	// @Tags admin
+	// @Security BearerAuth
+	// @Produce json
+	// @Param id path int true "Delivery zone ID"
+	// @Success 200 {object} repository.DeliveryZone
+	// @Router /admin/delivery-zones/{id} [get]
+	admin.GET("/delivery-zones/:id", handlers.GetDeliveryZone)
+
+	// @Summary Update delivery zone
+	// @Description Change a delivery zone (admin only)
+	// @Tags admin
+	// @Security BearerAuth
+	// @Accept json
+	// @Produce json
+	// @Param id path int true "Delivery zone ID"
+	// @Param request body handlers.DeliveryZoneRequest true "Delivery zone"
+	// @Success 200 {object} repository.DeliveryZone
+	// @Router /admin/delivery-zones/{id} [put]
+	admin.PUT("/delivery-zones/:id", handlers.UpdateDeliveryZone)
+
+	// @Summary Delete delivery zone
+	// @Description Delete a delivery zone (admin only)
+	// @Tags admin
+	// @Security BearerAuth
+	// @Produce json
+	// @Param id path int true "Delivery zone ID"
+	// @Success 200 {object} handlers.Response
+	// @Router /admin/delivery-zones/{id} [delete]
+	admin.DELETE("/delivery-zones/:id", handlers.DeleteDeliveryZone)
+
 	r.Run(":8080")
 }
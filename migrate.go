@@ -0,0 +1,53 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+
+	"github.com/Anwarjondev/fast-food/internal/db"
+)
+
+const migrateUsage = "usage: fast-food migrate up|down [steps]|status"
+
+// runMigrate implements the migrate subcommand
+func runMigrate(dsn string, args []string) {
+	if len(args) == 0 {
+		log.Fatal(migrateUsage)
+	}
+	db.Open(dsn)
+
+	switch args[0] {
+	case "up":
+		if err := db.MigrateUp(); err != nil {
+			log.Fatal(err)
+		}
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			n, err := strconv.Atoi(args[1])
+			if err != nil || n < 1 {
+				log.Fatal(migrateUsage)
+			}
+			steps = n
+		}
+		if err := db.MigrateDown(steps); err != nil {
+			log.Fatal(err)
+		}
+	case "status":
+		status, err := db.GetMigrationStatus()
+		if err != nil {
+			log.Fatal(err)
+		}
+		for _, s := range status {
+			applied := "pending"
+			if s.AppliedAt != nil {
+				applied = s.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+			fmt.Fprintf(os.Stdout, "%04d  %-30s %s\n", s.Version, s.Name, applied)
+		}
+	default:
+		log.Fatal(migrateUsage)
+	}
+}